@@ -0,0 +1,227 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+const (
+	// Addresses allocated by ODL
+	ModeODL = "odl"
+	// Addresses allocated locally from the PodCIDRs of the node
+	ModeNode = "node"
+
+	// Default kubeconfig of the node mode, the kubelet's one
+	DefaultKubeconfig = "/etc/kubernetes/kubelet.conf"
+
+	// Default directory of the lease cache, a subdirectory per network
+	DefaultDataDir = "/var/lib/cni/odl-ipam"
+
+	// Default timeout of the requests to ODL, in seconds
+	DefaultTimeout = 10
+)
+
+//Example of the expected json
+//{
+//    "cniVersion":"0.3.0",
+//    "name":"odl-cni",
+//    "type":"odlovs-cni",
+//    "ipam":{
+//        "type":"odl-ipam",
+//        "odlUrl":"http://192.168.33.1:8181",
+//        "username":"admin",
+//        "password":"admin",
+//        "subnet":"10.11.1.0/24",
+//        "rangeStart":"10.11.1.10",
+//        "rangeEnd":"10.11.1.250",
+//        "gateway":"10.11.1.1",
+//        "routes":[{
+//            "dst":"0.0.0.0/0"
+//        }],
+//        "dns":{
+//            "nameservers":["10.96.0.10"],
+//            "search":["default.svc.cluster.local"]
+//        }
+//    }
+//}
+//
+//In the node mode, the subnets are the PodCIDRs of the node, IPv4 and/or
+//IPv6, so that the same configuration fits every node:
+//{
+//    "cniVersion":"0.3.0",
+//    "name":"odl-cni",
+//    "type":"odlovs-cni",
+//    "ipam":{
+//        "type":"odl-ipam",
+//        "mode":"node",
+//        "kubeconfig":"/etc/kubernetes/kubelet.conf"
+//    }
+//}
+
+// The odl-ipam config type
+type IPAMConfig struct {
+	Type string `json:"type"`
+	// Either odl, the default, or node
+	Mode string `json:"mode"`
+	// Url of ODL's RESTCONF server
+	OdlURL   string `json:"odlUrl"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Name of the id-manager pool the addresses are allocated from,
+	// derived from the network name and the subnet by default
+	Pool       string         `json:"pool"`
+	Subnet     types.IPNet    `json:"subnet"`
+	RangeStart net.IP         `json:"rangeStart"`
+	RangeEnd   net.IP         `json:"rangeEnd"`
+	Gateway    net.IP         `json:"gateway"`
+	Routes     []*types.Route `json:"routes"`
+	DNS        types.DNS      `json:"dns"`
+	// Node mode: the kubeconfig used to read the node, and its name,
+	// the lower case hostname by default
+	Kubeconfig string `json:"kubeconfig"`
+	NodeName   string `json:"nodeName"`
+	// Directory of the lease cache
+	DataDir string `json:"dataDir"`
+	// Timeout of the requests to ODL, in seconds
+	Timeout int `json:"timeout"`
+}
+
+// The network config, of which odl-ipam only reads its own section
+type NetConf struct {
+	CNIVersion string      `json:"cniVersion"`
+	Name       string      `json:"name"`
+	IPAM       *IPAMConfig `json:"ipam"`
+	// The result of the ADD, passed to CHECK
+	RawPrevResult map[string]interface{} `json:"prevResult"`
+	PrevResult    types.Result           `json:"-"`
+}
+
+// parse the ipam section of the network config and fill in the defaults
+func parseIPAMConf(stdin []byte) (*IPAMConfig, *NetConf, error) {
+	netConf := &NetConf{}
+	if err := json.Unmarshal(stdin, netConf); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse network configuration: %v", err)
+	}
+	if netConf.IPAM == nil {
+		return nil, nil, fmt.Errorf("missing ipam section in network configuration")
+	}
+	conf := netConf.IPAM
+	if err := netConf.parsePrevResult(); err != nil {
+		return nil, nil, err
+	}
+
+	if conf.DataDir == "" {
+		conf.DataDir = DefaultDataDir
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = DefaultTimeout
+	}
+
+	switch conf.Mode {
+	case "", ModeODL:
+		conf.Mode = ModeODL
+		if err := conf.setRange(netConf.Name); err != nil {
+			return nil, nil, err
+		}
+		if conf.OdlURL == "" {
+			return nil, nil, fmt.Errorf("ipam: odlUrl is required")
+		}
+	case ModeNode:
+		if conf.Kubeconfig == "" {
+			conf.Kubeconfig = DefaultKubeconfig
+		}
+		if conf.NodeName == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, nil, fmt.Errorf("ipam: nodeName is required: %v", err)
+			}
+			conf.NodeName = strings.ToLower(hostname)
+		}
+	default:
+		return nil, nil, fmt.Errorf("ipam: unknown mode %q, expected %s or %s", conf.Mode, ModeODL, ModeNode)
+	}
+	return conf, netConf, nil
+}
+
+// setRange checks the subnet, the range and the gateway, and fills in the
+// ones missing: the gateway is the first address of the subnet and the range
+// covers the other ones.
+func (conf *IPAMConfig) setRange(network string) error {
+	subnet := net.IPNet(conf.Subnet)
+	if subnet.IP == nil {
+		return fmt.Errorf("ipam: subnet is required")
+	}
+	subnet.IP = subnet.IP.Mask(subnet.Mask)
+	conf.Subnet = types.IPNet(subnet)
+
+	if conf.Gateway == nil {
+		conf.Gateway = ipAdd(subnet.IP, 1)
+	} else if !subnet.Contains(conf.Gateway) {
+		return fmt.Errorf("ipam: gateway %s is not in subnet %s", conf.Gateway, subnet.String())
+	}
+
+	low, high := uint32(1), lastOffset(&subnet)
+	if conf.RangeStart != nil {
+		offset, err := offsetOf(&subnet, conf.RangeStart)
+		if err != nil {
+			return fmt.Errorf("ipam: rangeStart: %v", err)
+		}
+		low = offset
+	} else if conf.Gateway.Equal(ipAdd(subnet.IP, low)) {
+		low++
+	}
+	if conf.RangeEnd != nil {
+		offset, err := offsetOf(&subnet, conf.RangeEnd)
+		if err != nil {
+			return fmt.Errorf("ipam: rangeEnd: %v", err)
+		}
+		high = offset
+	}
+	if low == 0 || low > high {
+		return fmt.Errorf("ipam: empty address range in subnet %s", subnet.String())
+	}
+	if gateway, _ := offsetOf(&subnet, conf.Gateway); gateway >= low && gateway <= high {
+		return fmt.Errorf("ipam: gateway %s is in the address range", conf.Gateway)
+	}
+	conf.RangeStart = ipAdd(subnet.IP, low)
+	conf.RangeEnd = ipAdd(subnet.IP, high)
+
+	if conf.Pool == "" {
+		conf.Pool = fmt.Sprintf("%s-%s", network, subnet.String())
+	}
+	return nil
+}
+
+// Offsets of the address range in the subnet
+func (conf *IPAMConfig) offsets() (low, high uint32) {
+	subnet := net.IPNet(conf.Subnet)
+	low, _ = offsetOf(&subnet, conf.RangeStart)
+	high, _ = offsetOf(&subnet, conf.RangeEnd)
+	return low, high
+}
+
+func (netConf *NetConf) parsePrevResult() error {
+	if netConf.RawPrevResult == nil {
+		return nil
+	}
+	conf := &types.NetConf{CNIVersion: netConf.CNIVersion, RawPrevResult: netConf.RawPrevResult}
+	if err := version.ParsePrevResult(conf); err != nil {
+		return fmt.Errorf("failed to parse prevResult: %v", err)
+	}
+	netConf.PrevResult = conf.PrevResult
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// Normalize the address to 4 bytes for IPv4
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+// Address at offset from ip
+func ipAdd(ip net.IP, offset uint32) net.IP {
+	ip = normalizeIP(ip)
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), big.NewInt(int64(offset)))
+	bytes := sum.Bytes()
+	result := make(net.IP, len(ip))
+	copy(result[len(result)-len(bytes):], bytes)
+	return result
+}
+
+// Offset of ip from the subnet address
+func offsetOf(subnet *net.IPNet, ip net.IP) (uint32, error) {
+	if !subnet.Contains(ip) {
+		return 0, fmt.Errorf("%s is not in subnet %s", ip, subnet.String())
+	}
+	diff := new(big.Int).Sub(new(big.Int).SetBytes(normalizeIP(ip)), new(big.Int).SetBytes(normalizeIP(subnet.IP)))
+	if !diff.IsUint64() || diff.Uint64() > math.MaxUint32 {
+		return 0, fmt.Errorf("%s is too far from the start of subnet %s", ip, subnet.String())
+	}
+	return uint32(diff.Uint64()), nil
+}
+
+// Offset of the last host address of the subnet, the IPv4 broadcast address
+// excluded. Offsets are limited to 32 bits, the size of the ODL ids.
+func lastOffset(subnet *net.IPNet) uint32 {
+	ones, bits := subnet.Mask.Size()
+	hostBits := uint(bits - ones)
+	if hostBits >= 32 {
+		return math.MaxUint32 - 1
+	}
+	last := uint32(1)<<hostBits - 1
+	if bits == 32 && last > 0 {
+		last--
+	}
+	return last
+}
+
+// IP version of the address, "4" or "6"
+func ipVersion(ip net.IP) string {
+	if ip.To4() != nil {
+		return "4"
+	}
+	return "6"
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	leaseSuffix   = ".lease"
+	releaseSuffix = ".release"
+	lockFile      = "lock"
+)
+
+// An address allocated to a container interface
+type lease struct {
+	// The id-manager pool and key of the address
+	Pool string `json:"pool"`
+	Key  string `json:"key"`
+	IP   net.IP `json:"ip"`
+	// Number of times ODL failed to release the address
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// On-disk lease cache of a network: a file per lease, and a file per lease
+// still to be released in ODL. The plugin invocations are serialized by an
+// exclusive lock on the lock file.
+type leaseStore struct {
+	dir  string
+	lock *os.File
+}
+
+// Open the lease cache of the network and lock it
+func openLeaseStore(dataDir, network string) (*leaseStore, error) {
+	dir := filepath.Join(dataDir, network)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return &leaseStore{dir: dir, lock: lock}, nil
+}
+
+// Unlock the lease cache
+func (s *leaseStore) Close() error {
+	syscall.Flock(int(s.lock.Fd()), syscall.LOCK_UN)
+	return s.lock.Close()
+}
+
+// The lease of key, nil if there is none
+func (s *leaseStore) get(key string) (*lease, error) {
+	return s.read(s.path(key, leaseSuffix))
+}
+
+func (s *leaseStore) put(l *lease) error {
+	return s.write(s.path(l.Key, leaseSuffix), l)
+}
+
+// Move the lease to the ones to release
+func (s *leaseStore) markReleased(l *lease) error {
+	if err := s.write(s.path(l.Key, releaseSuffix), l); err != nil {
+		return err
+	}
+	return removeIfExists(s.path(l.Key, leaseSuffix))
+}
+
+// The leases still to be released
+func (s *leaseStore) pendingReleases() ([]*lease, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+releaseSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var leases []*lease
+	for _, path := range paths {
+		l, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			leases = append(leases, l)
+		}
+	}
+	return leases, nil
+}
+
+func (s *leaseStore) updateRelease(l *lease) error {
+	return s.write(s.path(l.Key, releaseSuffix), l)
+}
+
+// Forget the lease once released
+func (s *leaseStore) released(l *lease) error {
+	return removeIfExists(s.path(l.Key, releaseSuffix))
+}
+
+// File of the key, which holds the container id and interface name
+func (s *leaseStore) path(key, suffix string) string {
+	return filepath.Join(s.dir, strings.Replace(key, string(filepath.Separator), "_", -1)+suffix)
+}
+
+func (s *leaseStore) read(path string) (*lease, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := &lease{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write the file atomically, so that a crash never leaves it truncated
+func (s *leaseStore) write(path string, l *lease) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
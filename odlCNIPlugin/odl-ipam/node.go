@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// File caching the PodCIDRs of the node, used when the API server is
+	// unreachable
+	podCIDRsFile = "podcidrs"
+
+	// Prefix of the files holding the last address allocated per IP
+	// version, the next allocation starting after it
+	lastReservedPrefix = "last_reserved_ip."
+)
+
+// Allocate an address of each PodCIDR of the node to key, or return the
+// ones it already holds. The lease cache holds a file per allocated
+// address, named after the address and holding the key.
+func allocateFromNode(conf *IPAMConfig, store *leaseStore, key string) (*current.Result, error) {
+	cidrs, err := nodePodCIDRs(conf, store)
+	if err != nil {
+		return nil, err
+	}
+	held, err := store.addressesOf(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &current.Result{DNS: conf.DNS}
+	var allocated []net.IP
+	families := make(map[string]bool)
+	for _, cidr := range cidrs {
+		address := findAddress(held, cidr)
+		if address == nil {
+			address, err = store.reserveAddress(cidr, key)
+			if err != nil {
+				// Do not keep part of the addresses of a failed ADD
+				for _, ip := range allocated {
+					store.releaseAddress(ip)
+				}
+				return nil, err
+			}
+			allocated = append(allocated, address)
+		}
+		families[ipVersion(address)] = true
+		result.IPs = append(result.IPs, &current.IPConfig{
+			Address: net.IPNet{IP: address, Mask: cidr.Mask},
+			Gateway: ipAdd(cidr.IP, 1),
+		})
+	}
+
+	for _, route := range conf.Routes {
+		if families[ipVersion(route.Dst.IP)] {
+			result.Routes = append(result.Routes, route)
+		}
+	}
+	if len(conf.Routes) == 0 {
+		// Default route of each IP family through its gateway
+		for _, ipc := range result.IPs {
+			bits := len(ipc.Address.Mask) * 8
+			dst := net.IPNet{IP: make(net.IP, len(ipc.Address.IP)), Mask: net.CIDRMask(0, bits)}
+			result.Routes = append(result.Routes, &types.Route{Dst: dst})
+		}
+	}
+	return result, nil
+}
+
+// Release the addresses of key
+func releaseFromNode(store *leaseStore, key string) error {
+	held, err := store.addressesOf(key)
+	if err != nil {
+		return err
+	}
+	for _, ip := range held {
+		if err := store.releaseAddress(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The PodCIDRs of the node from the API server, or from the cache when the
+// API server is unreachable
+func nodePodCIDRs(conf *IPAMConfig, store *leaseStore) ([]*net.IPNet, error) {
+	cache := filepath.Join(store.dir, podCIDRsFile)
+	cidrs, err := readNodePodCIDRs(conf)
+	if err == nil {
+		if err := ioutil.WriteFile(cache, []byte(strings.Join(cidrs, "\n")), 0644); err != nil {
+			log.Warnf("Error caching the PodCIDRs of node %s: %v", conf.NodeName, err)
+		}
+	} else {
+		data, cacheErr := ioutil.ReadFile(cache)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("Error reading the PodCIDRs of node %s: %v", conf.NodeName, err)
+		}
+		log.Warnf("Using the cached PodCIDRs of node %s: %v", conf.NodeName, err)
+		cidrs = strings.Fields(string(data))
+	}
+
+	var subnets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing PodCIDR %q of node %s: %v", cidr, conf.NodeName, err)
+		}
+		subnet.IP = normalizeIP(subnet.IP)
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+func readNodePodCIDRs(conf *IPAMConfig) ([]string, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = time.Duration(conf.Timeout) * time.Second
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	node, err := clientSet.CoreV1().Nodes().Get(conf.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cidrs := node.Spec.PodCIDRs
+	if len(cidrs) == 0 && node.Spec.PodCIDR != "" {
+		cidrs = []string{node.Spec.PodCIDR}
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("node %s has no PodCIDR", conf.NodeName)
+	}
+	return cidrs, nil
+}
+
+func findAddress(addresses []net.IP, subnet *net.IPNet) net.IP {
+	for _, ip := range addresses {
+		if subnet.Contains(ip) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// The addresses allocated to key
+func (s *leaseStore) addressesOf(key string) ([]net.IP, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []net.IP
+	for _, file := range files {
+		ip := net.ParseIP(file.Name())
+		if ip == nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(data)) == key {
+			addresses = append(addresses, normalizeIP(ip))
+		}
+	}
+	return addresses, nil
+}
+
+// Reserve the first free address of the subnet after the last one
+// reserved, the first one being the gateway
+func (s *leaseStore) reserveAddress(subnet *net.IPNet, key string) (net.IP, error) {
+	version := ipVersion(subnet.IP)
+	last := filepath.Join(s.dir, lastReservedPrefix+version)
+	low, high := uint32(2), lastOffset(subnet)
+	if low > high {
+		return nil, fmt.Errorf("PodCIDR %s is too small", subnet.String())
+	}
+
+	start := low
+	if data, err := ioutil.ReadFile(last); err == nil {
+		if ip := net.ParseIP(strings.TrimSpace(string(data))); ip != nil {
+			if offset, err := offsetOf(subnet, ip); err == nil && offset >= low && offset < high {
+				start = offset + 1
+			}
+		}
+	}
+
+	offset := start
+	for {
+		ip := ipAdd(subnet.IP, offset)
+		file, err := os.OpenFile(filepath.Join(s.dir, ip.String()), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.WriteString(key)
+			file.Close()
+			if err != nil {
+				s.releaseAddress(ip)
+				return nil, err
+			}
+			if err := ioutil.WriteFile(last, []byte(ip.String()), 0644); err != nil {
+				log.Warnf("Error recording the last reserved address: %v", err)
+			}
+			return ip, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if offset == high {
+			offset = low
+		} else {
+			offset++
+		}
+		if offset == start {
+			return nil, fmt.Errorf("no address left in PodCIDR %s", subnet.String())
+		}
+	}
+}
+
+func (s *leaseStore) releaseAddress(ip net.IP) error {
+	return removeIfExists(filepath.Join(s.dir, ip.String()))
+}
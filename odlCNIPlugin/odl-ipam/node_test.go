@@ -0,0 +1,268 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nodeConf parses a node mode configuration whose API server is unreachable,
+// the PodCIDRs of the node coming from the cache of the lease store.
+func nodeConf(t *testing.T, routes string, cidrs ...string) (*IPAMConfig, *leaseStore) {
+	t.Helper()
+	dir := t.TempDir()
+	conf, _, err := parseIPAMConf([]byte(`{
+		"cniVersion": "1.0.0",
+		"name": "odl-net",
+		"ipam": {
+			"type": "odl-ipam",
+			"mode": "node",
+			"kubeconfig": "` + filepath.Join(dir, "missing.conf") + `",
+			"nodeName": "node-1",
+			"dataDir": "` + dir + `",
+			"routes": [` + routes + `]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("parseIPAMConf() = %v", err)
+	}
+	store, err := openLeaseStore(conf.DataDir, "odl-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := ioutil.WriteFile(filepath.Join(store.dir, podCIDRsFile), []byte(strings.Join(cidrs, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return conf, store
+}
+
+func TestReserveAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		subnet string
+		// addresses reserved in order, "" for exhaustion
+		expected []string
+		fails    bool
+	}{
+		{
+			name:     "in order",
+			subnet:   "10.11.1.0/24",
+			expected: []string{"10.11.1.2", "10.11.1.3", "10.11.1.4"},
+		},
+		{
+			// the gateway and the broadcast address are never reserved
+			name:     "exhaustion",
+			subnet:   "10.11.1.0/29",
+			expected: []string{"10.11.1.2", "10.11.1.3", "10.11.1.4", "10.11.1.5", "10.11.1.6", ""},
+		},
+		{
+			name:     "single address",
+			subnet:   "10.11.1.0/30",
+			expected: []string{"10.11.1.2", ""},
+		},
+		{name: "too small", subnet: "10.11.1.0/31", fails: true},
+		{
+			name:     "ipv6",
+			subnet:   "fd00::/126",
+			expected: []string{"fd00::2", "fd00::3", ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, store := nodeConf(t, "")
+			_, subnet, _ := net.ParseCIDR(test.subnet)
+			subnet.IP = normalizeIP(subnet.IP)
+			if test.fails {
+				if ip, err := store.reserveAddress(subnet, "c1:eth0"); err == nil {
+					t.Errorf("reserveAddress() = %s, expected a failure", ip)
+				}
+				return
+			}
+			for i, expected := range test.expected {
+				ip, err := store.reserveAddress(subnet, "c1:eth0")
+				if expected == "" {
+					if err == nil || !strings.Contains(err.Error(), "no address left") {
+						t.Errorf("reservation %d = %s, %v, expected the subnet exhausted", i, ip, err)
+					}
+					continue
+				}
+				if err != nil || ip.String() != expected {
+					t.Errorf("reservation %d = %s, %v, expected %s", i, ip, err, expected)
+				}
+			}
+		})
+	}
+}
+
+// The reservations go on after the last address reserved rather than reusing
+// the one just released, then wrap around to the start of the subnet.
+func TestReserveAddressWrapAround(t *testing.T) {
+	_, store := nodeConf(t, "")
+	_, subnet, _ := net.ParseCIDR("10.11.1.0/29")
+	reserve := func() string {
+		ip, err := store.reserveAddress(subnet, "c1:eth0")
+		if err != nil {
+			return err.Error()
+		}
+		return ip.String()
+	}
+
+	for _, expected := range []string{"10.11.1.2", "10.11.1.3", "10.11.1.4"} {
+		if ip := reserve(); ip != expected {
+			t.Fatalf("reserved %s, expected %s", ip, expected)
+		}
+	}
+	store.releaseAddress(net.ParseIP("10.11.1.2").To4())
+	store.releaseAddress(net.ParseIP("10.11.1.3").To4())
+
+	var reserved []string
+	for i := 0; i < 4; i++ {
+		reserved = append(reserved, reserve())
+	}
+	expected := []string{"10.11.1.5", "10.11.1.6", "10.11.1.2", "10.11.1.3"}
+	if !reflect.DeepEqual(reserved, expected) {
+		t.Errorf("reserved %v, expected %v", reserved, expected)
+	}
+
+	// a last reserved address out of the subnet is ignored
+	if err := ioutil.WriteFile(filepath.Join(store.dir, lastReservedPrefix+"4"), []byte("192.168.0.1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.releaseAddress(net.ParseIP("10.11.1.4").To4())
+	if ip := reserve(); ip != "10.11.1.4" {
+		t.Errorf("reserved %s, expected 10.11.1.4", ip)
+	}
+}
+
+func TestAllocateFromNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidrs     []string
+		routes    string
+		addresses []string
+		gateways  []string
+		// route destinations of the result
+		dsts []string
+	}{
+		{
+			name:      "ipv4 default route",
+			cidrs:     []string{"10.11.1.0/24"},
+			addresses: []string{"10.11.1.2/24"},
+			gateways:  []string{"10.11.1.1"},
+			dsts:      []string{"0.0.0.0/0"},
+		},
+		{
+			name:      "dual stack default routes",
+			cidrs:     []string{"10.11.1.0/24", "fd00:1::/64"},
+			addresses: []string{"10.11.1.2/24", "fd00:1::2/64"},
+			gateways:  []string{"10.11.1.1", "fd00:1::1"},
+			dsts:      []string{"0.0.0.0/0", "::/0"},
+		},
+		{
+			name:      "ipv6 only",
+			cidrs:     []string{"fd00:1::/64"},
+			addresses: []string{"fd00:1::2/64"},
+			gateways:  []string{"fd00:1::1"},
+			dsts:      []string{"::/0"},
+		},
+		{
+			// the same configuration fits single and dual stack nodes
+			name:      "routes of the node families",
+			cidrs:     []string{"10.11.1.0/24"},
+			routes:    `{"dst": "0.0.0.0/0"}, {"dst": "::/0"}, {"dst": "10.96.0.0/12"}`,
+			addresses: []string{"10.11.1.2/24"},
+			gateways:  []string{"10.11.1.1"},
+			dsts:      []string{"0.0.0.0/0", "10.96.0.0/12"},
+		},
+		{
+			name:      "dual stack routes",
+			cidrs:     []string{"10.11.1.0/24", "fd00:1::/64"},
+			routes:    `{"dst": "10.96.0.0/12"}, {"dst": "fd00:96::/108"}`,
+			addresses: []string{"10.11.1.2/24", "fd00:1::2/64"},
+			gateways:  []string{"10.11.1.1", "fd00:1::1"},
+			dsts:      []string{"10.96.0.0/12", "fd00:96::/108"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, store := nodeConf(t, test.routes, test.cidrs...)
+			result, err := allocateFromNode(conf, store, "c1:eth0")
+			if err != nil {
+				t.Fatalf("allocateFromNode() = %v", err)
+			}
+			var addresses, gateways, dsts []string
+			for _, ip := range result.IPs {
+				addresses = append(addresses, ip.Address.String())
+				gateways = append(gateways, ip.Gateway.String())
+			}
+			for _, route := range result.Routes {
+				dsts = append(dsts, route.Dst.String())
+			}
+			if !reflect.DeepEqual(addresses, test.addresses) || !reflect.DeepEqual(gateways, test.gateways) {
+				t.Errorf("allocated %v through %v, expected %v through %v", addresses, gateways, test.addresses, test.gateways)
+			}
+			if !reflect.DeepEqual(dsts, test.dsts) {
+				t.Errorf("routes %v, expected %v", dsts, test.dsts)
+			}
+
+			// a repeated ADD returns the same addresses
+			again, err := allocateFromNode(conf, store, "c1:eth0")
+			if err != nil || !reflect.DeepEqual(again.IPs, result.IPs) {
+				t.Errorf("repeated allocateFromNode() = %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestReleaseFromNode(t *testing.T) {
+	conf, store := nodeConf(t, "", "fd00:1::/64", "10.11.1.0/30")
+	if _, err := allocateFromNode(conf, store, "c1:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	// the only IPv4 address is held, so the ADD fails without keeping the
+	// IPv6 address it reserved
+	if _, err := allocateFromNode(conf, store, "c2:eth0"); err == nil {
+		t.Fatal("allocateFromNode() succeeded in an exhausted PodCIDR")
+	}
+	if held, _ := store.addressesOf("c2:eth0"); len(held) != 0 {
+		t.Errorf("the failed ADD kept %v", held)
+	}
+
+	if err := releaseFromNode(store, "c1:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := store.addressesOf("c1:eth0"); len(held) != 0 {
+		t.Errorf("addresses %v still held", held)
+	}
+	result, err := allocateFromNode(conf, store, "c2:eth0")
+	if err != nil || len(result.IPs) != 2 || result.IPs[1].Address.String() != "10.11.1.2/30" {
+		t.Errorf("allocateFromNode() after the release = %+v, %v", result, err)
+	}
+}
+
+// The PodCIDRs come from the cache while the API server is unreachable,
+// and are unknown without it.
+func TestNodePodCIDRsUnavailable(t *testing.T) {
+	conf, store := nodeConf(t, "", "10.11.1.0/24")
+	if cidrs, err := nodePodCIDRs(conf, store); err != nil || len(cidrs) != 1 || cidrs[0].String() != "10.11.1.0/24" {
+		t.Errorf("nodePodCIDRs() from the cache = %v, %v", cidrs, err)
+	}
+	if err := removeIfExists(filepath.Join(store.dir, podCIDRsFile)); err != nil {
+		t.Fatal(err)
+	}
+	if cidrs, err := nodePodCIDRs(conf, store); err == nil {
+		t.Errorf("nodePodCIDRs() without cache = %v, expected a failure", cidrs)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client of ODL's id-manager RPCs over RESTCONF. Each address of a subnet is
+// an id of a pool, its offset from the subnet address; id-manager allocates
+// the ids by key and returns the same id for the same key until released.
+type idManager struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// Error answered by ODL, as opposed to ODL being unreachable
+type odlError struct {
+	status int
+	body   string
+}
+
+func (e *odlError) Error() string {
+	return fmt.Sprintf("ODL responded with %d: %s", e.status, e.body)
+}
+
+func newIdManager(conf *IPAMConfig) *idManager {
+	return &idManager{
+		url:      strings.TrimSuffix(conf.OdlURL, "/") + "/restconf/operations/id-manager:",
+		username: conf.Username,
+		password: conf.Password,
+		client:   &http.Client{Timeout: time.Duration(conf.Timeout) * time.Second},
+	}
+}
+
+// Create the pool of the ids from low to high, if it does not exist
+func (m *idManager) createPool(pool string, low, high uint32) error {
+	input := map[string]interface{}{
+		"pool-name": pool,
+		"low":       low,
+		"high":      high,
+	}
+	return m.rpc("createIdPool", input, nil)
+}
+
+// Allocate the id of key in the pool
+func (m *idManager) allocate(pool, key string) (uint32, error) {
+	input := map[string]interface{}{
+		"pool-name": pool,
+		"id-key":    key,
+	}
+	var output struct {
+		IdValue uint32 `json:"id-value"`
+	}
+	if err := m.rpc("allocateId", input, &output); err != nil {
+		return 0, err
+	}
+	return output.IdValue, nil
+}
+
+// Release the id of key in the pool
+func (m *idManager) release(pool, key string) error {
+	input := map[string]interface{}{
+		"pool-name": pool,
+		"id-key":    key,
+	}
+	return m.rpc("releaseId", input, nil)
+}
+
+func (m *idManager) rpc(name string, input, output interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, m.url+name, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(m.username, m.password)
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return &odlError{status: res.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if output == nil || len(data) == 0 {
+		return nil
+	}
+	var reply struct {
+		Output json.RawMessage `json:"output"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("failed to parse %s output: %v", name, err)
+	}
+	return json.Unmarshal(reply.Output, output)
+}
+
+// Whether the request may succeed later, ODL being unreachable or failing
+func isTemporary(err error) bool {
+	odlErr, ok := err.(*odlError)
+	return !ok || odlErr.status >= http.StatusInternalServerError
+}
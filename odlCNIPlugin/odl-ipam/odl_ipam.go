@@ -9,27 +9,227 @@
 package main
 
 import (
-    "flag"
-    "fmt"
-    "github.com/containernetworking/cni/pkg/version"
-    "github.com/containernetworking/cni/pkg/skel"
+	"fmt"
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/cni/pkg/version"
 )
 
-const APP_VERSION = "0.1"
+const APP_VERSION = "0.2"
+
+// Number of errors answered by ODL before a release is given up
+const maxReleaseAttempts = 5
 
 func cmdAdd(args *skel.CmdArgs) error {
-    fmt.Println("Add Cmd.")
-    return nil
+	conf, netConf, err := parseIPAMConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	store, err := openLeaseStore(conf.DataDir, netConf.Name)
+	if err != nil {
+		return fmt.Errorf("Error opening the lease cache: %v", err)
+	}
+	defer store.Close()
+
+	var result *current.Result
+	if conf.Mode == ModeNode {
+		result, err = allocateFromNode(conf, store, leaseKey(args))
+	} else {
+		result, err = allocateFromODL(conf, store, leaseKey(args))
+	}
+	if err != nil {
+		return err
+	}
+	return types.PrintResult(result, netConf.CNIVersion)
+}
+
+// Allocate an address of the subnet from ODL to key, or return the one it
+// already holds
+func allocateFromODL(conf *IPAMConfig, store *leaseStore, key string) (*current.Result, error) {
+	odl := newIdManager(conf)
+	l, err := store.get(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the lease cache: %v", err)
+	}
+	// A lease is only cached once ODL allocated it, so that a repeated ADD
+	// returns the same address without asking ODL again
+	if l != nil && l.Pool != conf.Pool {
+		// The pool changed with the configuration
+		if err := store.markReleased(l); err != nil {
+			return nil, fmt.Errorf("Error updating the lease cache: %v", err)
+		}
+		l = nil
+	}
+	if l == nil {
+		low, high := conf.offsets()
+		if err := odl.createPool(conf.Pool, low, high); err != nil {
+			return nil, fmt.Errorf("Error creating the ODL id pool %s: %v", conf.Pool, err)
+		}
+		id, err := odl.allocate(conf.Pool, key)
+		if err != nil {
+			return nil, fmt.Errorf("Error allocating an address from ODL: %v", err)
+		}
+		subnet := net.IPNet(conf.Subnet)
+		l = &lease{Pool: conf.Pool, Key: key, IP: ipAdd(subnet.IP, id)}
+		if !subnet.Contains(l.IP) {
+			return nil, fmt.Errorf("ODL allocated id %d out of subnet %s", id, subnet.String())
+		}
+		if err := store.put(l); err != nil {
+			odl.release(conf.Pool, key)
+			return nil, fmt.Errorf("Error caching the lease: %v", err)
+		}
+		// ODL is reachable, release what could not be released before
+		releasePending(store, odl)
+	}
+
+	return &current.Result{
+		IPs: []*current.IPConfig{{
+			Address: net.IPNet{IP: l.IP, Mask: conf.Subnet.Mask},
+			Gateway: conf.Gateway,
+		}},
+		Routes: conf.Routes,
+		DNS:    conf.DNS,
+	}, nil
 }
 
 func cmdDel(args *skel.CmdArgs) error {
-    fmt.Println("Add Cmd.")
-    return nil
+	conf, netConf, err := parseIPAMConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	store, err := openLeaseStore(conf.DataDir, netConf.Name)
+	if err != nil {
+		return fmt.Errorf("Error opening the lease cache: %v", err)
+	}
+	defer store.Close()
+
+	if conf.Mode == ModeNode {
+		return releaseFromNode(store, leaseKey(args))
+	}
+	return releaseFromODL(conf, store, leaseKey(args))
 }
 
-func main() {
-    flag.Parse() // Scan the arguments list 
+// Release the address of key in ODL, or record the release to retry it
+// later when ODL is unreachable
+func releaseFromODL(conf *IPAMConfig, store *leaseStore, key string) error {
+	l, err := store.get(key)
+	if err != nil {
+		return fmt.Errorf("Error reading the lease cache: %v", err)
+	}
+	if l == nil {
+		// Either already released, or the cache was lost: release by key
+		l = &lease{Pool: conf.Pool, Key: key}
+	}
+	// Record the release first, so that it is retried if ODL is unreachable
+	if err := store.markReleased(l); err != nil {
+		return fmt.Errorf("Error updating the lease cache: %v", err)
+	}
+
+	odl := newIdManager(conf)
+	if err := releaseLease(store, odl, l); err != nil {
+		log.Warnf("Address %s of %s will be released in ODL later: %v", l.IP, key, err)
+		return nil
+	}
+	releasePending(store, odl)
+	return nil
+}
 
-    skel.PluginMain(cmdAdd, cmdDel, version.All)
+// Release the lease in ODL and forget it, or count the failure. Only errors
+// worth a retry are returned.
+func releaseLease(store *leaseStore, odl *idManager, l *lease) error {
+	err := odl.release(l.Pool, l.Key)
+	if err == nil {
+		return store.released(l)
+	}
+	if _, answered := err.(*odlError); answered {
+		l.Attempts++
+		if !isTemporary(err) || l.Attempts >= maxReleaseAttempts {
+			log.Errorf("Giving up releasing %s of %s in ODL: %v", l.IP, l.Key, err)
+			return store.released(l)
+		}
+		store.updateRelease(l)
+	}
+	return err
 }
 
+// Retry the releases that failed before, stopping at the first one failing
+func releasePending(store *leaseStore, odl *idManager) {
+	leases, err := store.pendingReleases()
+	if err != nil {
+		log.Errorf("Error reading the pending releases: %v", err)
+		return
+	}
+	for _, l := range leases {
+		if err := releaseLease(store, odl, l); err != nil {
+			return
+		}
+	}
+}
+
+// Check the container interface still holds its addresses, and that they are
+// the ones of the result of the ADD
+func cmdCheck(args *skel.CmdArgs) error {
+	conf, netConf, err := parseIPAMConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	store, err := openLeaseStore(conf.DataDir, netConf.Name)
+	if err != nil {
+		return fmt.Errorf("Error opening the lease cache: %v", err)
+	}
+	defer store.Close()
+
+	key := leaseKey(args)
+	var held []net.IP
+	if conf.Mode == ModeNode {
+		held, err = store.addressesOf(key)
+		if err != nil {
+			return fmt.Errorf("Error reading the lease cache: %v", err)
+		}
+	} else {
+		l, err := store.get(key)
+		if err != nil {
+			return fmt.Errorf("Error reading the lease cache: %v", err)
+		}
+		if l != nil && l.Pool == conf.Pool {
+			held = append(held, l.IP)
+		}
+	}
+	if len(held) == 0 {
+		return fmt.Errorf("no address allocated to %s", key)
+	}
+
+	if netConf.PrevResult == nil {
+		return nil
+	}
+	result, err := current.NewResultFromResult(netConf.PrevResult)
+	if err != nil {
+		return fmt.Errorf("Error converting the prevResult: %v", err)
+	}
+	for _, ip := range held {
+		found := false
+		for _, ipc := range result.IPs {
+			if ipc.Address.IP.Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("address %s of %s is not in prevResult", ip, key)
+		}
+	}
+	return nil
+}
+
+// id-manager key of the address of a container interface
+func leaseKey(args *skel.CmdArgs) string {
+	return fmt.Sprintf("%s:%s", args.ContainerID, args.IfName)
+}
+
+func main() {
+	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, version.All, "ODL IPAM plugin v"+APP_VERSION)
+}
@@ -0,0 +1,567 @@
+/*
+ * Copyright (c) 2017 Kontron - S & T Company and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIdManager is an in-process stand-in for ODL's id-manager RPCs. It
+// allocates the lowest free id of the pool to each key.
+type fakeIdManager struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	// status answered to the next releases instead of releasing, 0 for none
+	releaseStatus int
+	calls         []string
+	pools         map[string]*fakePool
+}
+
+type fakePool struct {
+	low, high uint32
+	ids       map[string]uint32
+}
+
+func newFakeIdManager(t *testing.T) *fakeIdManager {
+	m := &fakeIdManager{pools: make(map[string]*fakePool)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *fakeIdManager) handle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Input struct {
+			Pool string `json:"pool-name"`
+			Key  string `json:"id-key"`
+			Low  uint32 `json:"low"`
+			High uint32 `json:"high"`
+		} `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input := body.Input
+	rpc := strings.TrimPrefix(r.URL.Path, "/restconf/operations/id-manager:")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls = append(m.calls, rpc+" "+input.Key)
+	pool := m.pools[input.Pool]
+	switch rpc {
+	case "createIdPool":
+		if pool == nil {
+			m.pools[input.Pool] = &fakePool{low: input.Low, high: input.High, ids: make(map[string]uint32)}
+		}
+	case "allocateId":
+		if pool == nil {
+			http.Error(w, "no such pool", http.StatusNotFound)
+			return
+		}
+		id, ok := pool.ids[input.Key]
+		for candidate := pool.low; !ok && candidate <= pool.high; candidate++ {
+			if !pool.holds(candidate) {
+				id, ok = candidate, true
+			}
+		}
+		if !ok {
+			http.Error(w, "pool exhausted", http.StatusConflict)
+			return
+		}
+		pool.ids[input.Key] = id
+		json.NewEncoder(w).Encode(map[string]interface{}{"output": map[string]uint32{"id-value": id}})
+	case "releaseId":
+		if m.releaseStatus != 0 {
+			http.Error(w, "injected failure", m.releaseStatus)
+			return
+		}
+		if pool != nil {
+			delete(pool.ids, input.Key)
+		}
+	default:
+		http.Error(w, "unknown rpc", http.StatusNotFound)
+	}
+}
+
+func (p *fakePool) holds(id uint32) bool {
+	for _, held := range p.ids {
+		if held == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *fakeIdManager) setReleaseStatus(status int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.releaseStatus = status
+}
+
+func (m *fakeIdManager) count(rpc string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	n := 0
+	for _, call := range m.calls {
+		if strings.HasPrefix(call, rpc+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *fakeIdManager) held(pool string) map[string]uint32 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ids := make(map[string]uint32)
+	if p := m.pools[pool]; p != nil {
+		for key, id := range p.ids {
+			ids[key] = id
+		}
+	}
+	return ids
+}
+
+// odlConf parses an odl mode configuration using the fake and a lease cache
+// in a temporary directory.
+func odlConf(t *testing.T, url, subnet string) *IPAMConfig {
+	t.Helper()
+	conf, _, err := parseIPAMConf([]byte(`{
+		"cniVersion": "1.0.0",
+		"name": "odl-net",
+		"ipam": {
+			"type": "odl-ipam",
+			"odlUrl": "` + url + `",
+			"subnet": "` + subnet + `",
+			"dataDir": "` + t.TempDir() + `",
+			"timeout": 1
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("parseIPAMConf() = %v", err)
+	}
+	return conf
+}
+
+func openTestStore(t *testing.T, conf *IPAMConfig) *leaseStore {
+	t.Helper()
+	store, err := openLeaseStore(conf.DataDir, "odl-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestIPAdd(t *testing.T) {
+	tests := []struct {
+		ip       string
+		offset   uint32
+		expected string
+	}{
+		{"10.0.0.0", 1, "10.0.0.1"},
+		{"10.0.0.255", 1, "10.0.1.0"},
+		{"10.0.0.0", 65536, "10.1.0.0"},
+		{"::ffff:10.0.0.0", 2, "10.0.0.2"},
+		{"fd00::", 1, "fd00::1"},
+		{"fd00::ff", 1, "fd00::100"},
+		{"fd00::", math.MaxUint32, "fd00::ffff:ffff"},
+	}
+	for _, test := range tests {
+		ip := ipAdd(net.ParseIP(test.ip), test.offset)
+		if ip.String() != test.expected {
+			t.Errorf("ipAdd(%s, %d) = %s, expected %s", test.ip, test.offset, ip, test.expected)
+		}
+		if strings.Contains(test.expected, ".") && len(ip) != net.IPv4len {
+			t.Errorf("ipAdd(%s, %d) has %d bytes, expected an IPv4 address", test.ip, test.offset, len(ip))
+		}
+	}
+}
+
+func TestOffsetOf(t *testing.T) {
+	tests := []struct {
+		subnet string
+		ip     string
+		offset uint32
+		fails  bool
+	}{
+		{subnet: "10.0.0.0/24", ip: "10.0.0.0", offset: 0},
+		{subnet: "10.0.0.0/24", ip: "10.0.0.42", offset: 42},
+		{subnet: "10.0.0.0/16", ip: "10.0.1.2", offset: 258},
+		{subnet: "10.0.0.0/24", ip: "10.0.1.1", fails: true},
+		{subnet: "fd00::/64", ip: "fd00::1:0", offset: 65536},
+		// offsets are 32 bits, the size of the ODL ids
+		{subnet: "fd00::/64", ip: "fd00::1:0:0", fails: true},
+	}
+	for _, test := range tests {
+		_, subnet, _ := net.ParseCIDR(test.subnet)
+		offset, err := offsetOf(subnet, net.ParseIP(test.ip))
+		if (err != nil) != test.fails {
+			t.Errorf("offsetOf(%s, %s) error = %v, expected a failure: %t", test.subnet, test.ip, err, test.fails)
+			continue
+		}
+		if offset != test.offset {
+			t.Errorf("offsetOf(%s, %s) = %d, expected %d", test.subnet, test.ip, offset, test.offset)
+		}
+	}
+}
+
+func TestLastOffset(t *testing.T) {
+	tests := []struct {
+		subnet string
+		last   uint32
+	}{
+		// the broadcast address is excluded
+		{"10.0.0.0/24", 254},
+		{"10.0.0.0/30", 2},
+		{"10.0.0.0/31", 0},
+		{"10.0.0.0/32", 0},
+		{"fd00::/120", 255},
+		{"fd00::/96", math.MaxUint32 - 1},
+		{"fd00::/64", math.MaxUint32 - 1},
+	}
+	for _, test := range tests {
+		_, subnet, _ := net.ParseCIDR(test.subnet)
+		if last := lastOffset(subnet); last != test.last {
+			t.Errorf("lastOffset(%s) = %d, expected %d", test.subnet, last, test.last)
+		}
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		ipam    string
+		start   string
+		end     string
+		gateway string
+		fails   bool
+	}{
+		{name: "defaults", ipam: `"subnet": "10.11.1.0/24"`, start: "10.11.1.2", end: "10.11.1.254", gateway: "10.11.1.1"},
+		{name: "host bits cleared", ipam: `"subnet": "10.11.1.7/24"`, start: "10.11.1.2", end: "10.11.1.254", gateway: "10.11.1.1"},
+		{
+			name:    "explicit range",
+			ipam:    `"subnet": "10.11.1.0/24", "rangeStart": "10.11.1.10", "rangeEnd": "10.11.1.20", "gateway": "10.11.1.254"`,
+			start:   "10.11.1.10",
+			end:     "10.11.1.20",
+			gateway: "10.11.1.254",
+		},
+		{name: "gateway not first", ipam: `"subnet": "10.11.1.0/24", "gateway": "10.11.1.254", "rangeEnd": "10.11.1.253"`, start: "10.11.1.1", end: "10.11.1.253", gateway: "10.11.1.254"},
+		{name: "ipv6", ipam: `"subnet": "fd00::/120"`, start: "fd00::2", end: "fd00::ff", gateway: "fd00::1"},
+		{name: "no subnet", ipam: `"gateway": "10.11.1.1"`, fails: true},
+		{name: "gateway outside", ipam: `"subnet": "10.11.1.0/24", "gateway": "10.11.2.1"`, fails: true},
+		{name: "gateway in range", ipam: `"subnet": "10.11.1.0/24", "rangeStart": "10.11.1.1"`, fails: true},
+		{name: "start outside", ipam: `"subnet": "10.11.1.0/24", "rangeStart": "10.11.0.10"`, fails: true},
+		{name: "empty range", ipam: `"subnet": "10.11.1.0/24", "rangeStart": "10.11.1.20", "rangeEnd": "10.11.1.10"`, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, _, err := parseIPAMConf([]byte(`{"name": "odl-net", "ipam": {"odlUrl": "http://odl:8181", ` + test.ipam + `}}`))
+			if (err != nil) != test.fails {
+				t.Fatalf("parseIPAMConf() error = %v, expected a failure: %t", err, test.fails)
+			}
+			if test.fails {
+				return
+			}
+			if conf.RangeStart.String() != test.start || conf.RangeEnd.String() != test.end || conf.Gateway.String() != test.gateway {
+				t.Errorf("range %s-%s through %s, expected %s-%s through %s",
+					conf.RangeStart, conf.RangeEnd, conf.Gateway, test.start, test.end, test.gateway)
+			}
+			subnet := net.IPNet(conf.Subnet)
+			if conf.Pool != "odl-net-"+subnet.String() {
+				t.Errorf("pool %s, expected it named after the network and subnet", conf.Pool)
+			}
+		})
+	}
+}
+
+func TestLeaseStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openLeaseStore(dir, "odl-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	key := "container/1:eth0"
+	if l, err := store.get(key); l != nil || err != nil {
+		t.Fatalf("get() of an unknown key = %+v, %v", l, err)
+	}
+	l := &lease{Pool: "pool", Key: key, IP: net.ParseIP("10.11.1.2").To4()}
+	if err := store.put(l); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.get(key)
+	if err != nil || got == nil || got.Pool != "pool" || !got.IP.Equal(l.IP) {
+		t.Fatalf("get() = %+v, %v, expected %+v", got, err, l)
+	}
+
+	// the separator of the key does not make a subdirectory, and the
+	// atomic rewrite leaves no temporary file behind
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "odl-net"))
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if len(names) != 2 || names[0] != "container_1:eth0.lease" || names[1] != lockFile {
+		t.Errorf("cache holds %v, expected the lease and the lock", names)
+	}
+
+	if err := store.markReleased(got); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := store.get(key); l != nil {
+		t.Errorf("get() after markReleased() = %+v", l)
+	}
+	pending, err := store.pendingReleases()
+	if err != nil || len(pending) != 1 || pending[0].Key != key {
+		t.Fatalf("pendingReleases() = %+v, %v", pending, err)
+	}
+	pending[0].Attempts = 2
+	if err := store.updateRelease(pending[0]); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := store.pendingReleases(); len(pending) != 1 || pending[0].Attempts != 2 {
+		t.Errorf("pendingReleases() after updateRelease() = %+v", pending)
+	}
+	if err := store.released(pending[0]); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := store.pendingReleases(); len(pending) != 0 {
+		t.Errorf("pendingReleases() after released() = %+v", pending)
+	}
+	// forgetting twice is not an error
+	if err := store.released(pending[0]); err != nil {
+		t.Errorf("released() twice = %v", err)
+	}
+}
+
+// A truncated lease, which the atomic rewrite prevents, is reported rather
+// than read as no lease.
+func TestLeaseStoreCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openLeaseStore(dir, "odl-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := ioutil.WriteFile(store.path("key", leaseSuffix), []byte(`{"pool":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.get("key"); err == nil {
+		t.Error("get() of a truncated lease succeeded")
+	}
+}
+
+// The lease cache of a network is held by one invocation at a time.
+func TestLeaseStoreLock(t *testing.T) {
+	dir := t.TempDir()
+	first, err := openLeaseStore(dir, "odl-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan *leaseStore)
+	go func() {
+		second, err := openLeaseStore(dir, "odl-net")
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- second
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the lease cache was opened twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// another network is not serialized with this one
+	other, err := openLeaseStore(dir, "other-net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	first.Close()
+	select {
+	case second := <-locked:
+		second.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("the lease cache was not unlocked")
+	}
+}
+
+func TestAllocateFromODL(t *testing.T) {
+	odl := newFakeIdManager(t)
+	conf := odlConf(t, odl.URL, "10.11.1.0/24")
+	store := openTestStore(t, conf)
+
+	result, err := allocateFromODL(conf, store, "c1:eth0")
+	if err != nil {
+		t.Fatalf("allocateFromODL() = %v", err)
+	}
+	if len(result.IPs) != 1 || result.IPs[0].Address.String() != "10.11.1.2/24" || !result.IPs[0].Gateway.Equal(net.ParseIP("10.11.1.1")) {
+		t.Errorf("allocated %+v, expected 10.11.1.2/24 through 10.11.1.1", result.IPs)
+	}
+	if other, _ := allocateFromODL(conf, store, "c2:eth0"); other == nil || other.IPs[0].Address.String() != "10.11.1.3/24" {
+		t.Errorf("second container got %+v, expected 10.11.1.3/24", other)
+	}
+
+	// a repeated ADD is answered from the cache
+	again, err := allocateFromODL(conf, store, "c1:eth0")
+	if err != nil || again.IPs[0].Address.String() != "10.11.1.2/24" {
+		t.Errorf("repeated allocateFromODL() = %+v, %v", again, err)
+	}
+	if n := odl.count("allocateId"); n != 2 {
+		t.Errorf("%d allocations sent to ODL, expected one per container", n)
+	}
+
+	// a new pool releases the address of the former one
+	former := conf.Pool
+	conf.Pool = "renamed"
+	if _, err := allocateFromODL(conf, store, "c1:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, held := odl.held(former)["c1:eth0"]; held {
+		t.Error("the address of the former pool was not released")
+	}
+	if _, held := odl.held("renamed")["c1:eth0"]; !held {
+		t.Error("no address allocated from the new pool")
+	}
+}
+
+func TestReleaseFromODL(t *testing.T) {
+	tests := []struct {
+		name string
+		// status of the release in ODL, -1 when ODL is unreachable
+		status int
+		// number of failed attempts recorded before this release
+		attempts int
+		pending  bool
+		// attempts recorded once the release failed
+		recorded int
+	}{
+		{name: "released", status: 0},
+		{name: "unreachable", status: -1, pending: true},
+		{name: "server error", status: http.StatusServiceUnavailable, pending: true, recorded: 1},
+		{name: "last attempt", status: http.StatusServiceUnavailable, attempts: maxReleaseAttempts - 1},
+		{name: "rejected", status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			odl := newFakeIdManager(t)
+			conf := odlConf(t, odl.URL, "10.11.1.0/24")
+			store := openTestStore(t, conf)
+			if _, err := allocateFromODL(conf, store, "c1:eth0"); err != nil {
+				t.Fatal(err)
+			}
+			if test.attempts > 0 {
+				l, _ := store.get("c1:eth0")
+				l.Attempts = test.attempts
+				store.put(l)
+			}
+			switch test.status {
+			case -1:
+				odl.Close()
+			case 0:
+			default:
+				odl.setReleaseStatus(test.status)
+			}
+
+			// DEL succeeds whatever ODL answers
+			if err := releaseFromODL(conf, store, "c1:eth0"); err != nil {
+				t.Fatalf("releaseFromODL() = %v", err)
+			}
+			if l, _ := store.get("c1:eth0"); l != nil {
+				t.Errorf("lease %+v still cached", l)
+			}
+			pending, err := store.pendingReleases()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(pending) == 1) != test.pending {
+				t.Fatalf("pending releases %+v, expected one: %t", pending, test.pending)
+			}
+			if test.pending && pending[0].Attempts != test.recorded {
+				t.Errorf("%d attempts recorded, expected %d", pending[0].Attempts, test.recorded)
+			}
+			if test.status == 0 {
+				if _, held := odl.held(conf.Pool)["c1:eth0"]; held {
+					t.Error("address still held in ODL")
+				}
+			}
+		})
+	}
+}
+
+// A release failing with server errors is retried by the next invocations
+// reaching ODL, and given up after maxReleaseAttempts.
+func TestPendingReleaseRetries(t *testing.T) {
+	odl := newFakeIdManager(t)
+	conf := odlConf(t, odl.URL, "10.11.1.0/24")
+	store := openTestStore(t, conf)
+	if _, err := allocateFromODL(conf, store, "c1:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	odl.setReleaseStatus(http.StatusInternalServerError)
+	releaseFromODL(conf, store, "c1:eth0")
+
+	for attempt := 2; attempt <= maxReleaseAttempts; attempt++ {
+		// every ADD allocating from ODL retries the pending releases
+		if _, err := allocateFromODL(conf, store, fmt.Sprintf("c%d:eth0", attempt)); err != nil {
+			t.Fatal(err)
+		}
+		pending, _ := store.pendingReleases()
+		if attempt < maxReleaseAttempts && (len(pending) != 1 || pending[0].Attempts != attempt) {
+			t.Fatalf("after attempt %d pending releases are %+v", attempt, pending)
+		}
+		if attempt == maxReleaseAttempts && len(pending) != 0 {
+			t.Fatalf("release not given up after %d attempts: %+v", attempt, pending)
+		}
+	}
+	if n := odl.count("releaseId"); n != maxReleaseAttempts {
+		t.Errorf("%d releases sent, expected %d", n, maxReleaseAttempts)
+	}
+}
+
+// Once ODL is back, the pending releases go through.
+func TestPendingReleaseRecovers(t *testing.T) {
+	odl := newFakeIdManager(t)
+	conf := odlConf(t, odl.URL, "10.11.1.0/24")
+	store := openTestStore(t, conf)
+	if _, err := allocateFromODL(conf, store, "c1:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	odl.setReleaseStatus(http.StatusServiceUnavailable)
+	releaseFromODL(conf, store, "c1:eth0")
+	odl.setReleaseStatus(0)
+
+	if _, err := allocateFromODL(conf, store, "c2:eth0"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := store.pendingReleases(); len(pending) != 0 {
+		t.Errorf("pending releases %+v, expected none", pending)
+	}
+	if _, held := odl.held(conf.Pool)["c1:eth0"]; held {
+		t.Error("address still held in ODL")
+	}
+}
@@ -1,16 +1,45 @@
 module git.opendaylight.org/gerrit/p/coe.git/odlCNIPlugin/odlovs-cni
 
+go 1.17
+
 require (
 	github.com/Sirupsen/logrus v0.0.0-20170822132746-89742aefa4b2
 	github.com/cenkalti/hub v0.0.0-20160527103212-11382a9960d3
 	github.com/cenkalti/rpc2 v0.0.0-20170726070524-c51a77e5f664
-	github.com/containernetworking/cni v0.6.0-rc1
-	github.com/containernetworking/plugins v0.0.0-20170913094114-e256564546e8
-	github.com/coreos/go-iptables v0.2.0
+	github.com/containernetworking/cni v1.1.2
+	github.com/containernetworking/plugins v1.1.1
+	github.com/coreos/go-iptables v0.6.0
 	github.com/j-keck/arping v0.0.0-20160618110441-2cf9dc699c56
 	github.com/socketplane/libovsdb v0.0.0-20170116174820-4de3618546de
-	github.com/vishvananda/netlink v0.0.0-20170630184320-6e453822d85e
-	github.com/vishvananda/netns v0.0.0-20170219233438-54f0e4339ce7
-	golang.org/x/crypto v0.0.0-20161006174701-d172538b2cfc
-	golang.org/x/sys v0.0.0-20160601133225-076b54675315
+	github.com/vishvananda/netlink v1.1.1-0.20210330154013-f5de75959ad5
+	github.com/vishvananda/netns v0.0.0-20210104183010-2eb08e3e575f
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/sys v0.0.0-20210809222454-d867a43fc93e
+	k8s.io/apimachinery v0.17.17
+	k8s.io/client-go v0.17.17
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/google/gofuzz v1.0.0 // indirect
+	github.com/googleapis/gnostic v0.0.0-20170729233727-0c5108395e2d // indirect
+	github.com/imdario/mergo v0.3.5 // indirect
+	github.com/json-iterator/go v1.1.8 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.1 // indirect
+	github.com/safchain/ethtool v0.0.0-20210803160452-9aa261dae9b1 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781 // indirect
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45 // indirect
+	golang.org/x/text v0.3.6 // indirect
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4 // indirect
+	google.golang.org/protobuf v1.26.0 // indirect
+	gopkg.in/inf.v0 v0.9.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	k8s.io/api v0.17.17 // indirect
+	k8s.io/klog v1.0.0 // indirect
+	k8s.io/utils v0.0.0-20191114184206-e782cd3c129f // indirect
+	sigs.k8s.io/yaml v1.1.0 // indirect
 )
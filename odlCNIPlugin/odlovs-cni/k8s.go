@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2017 Kontron Canada and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Annotations of the pods overriding the network configuration
+const (
+	// VLAN of the access port of the pod
+	VlanAnnotation = "coe.opendaylight.org/vlan"
+	// Comma separated VLANs of the trunk port of the pod
+	TrunkAnnotation = "coe.opendaylight.org/trunk"
+	// Bandwidth of the pod, as used by the CNI bandwidth plugin
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+// Timeout of the requests to the API server
+const apiTimeout = 10 * time.Second
+
+// Highest VLAN id
+const maxVlan = 4094
+
+// podAnnotations reads the annotations of the pod from the API server. They
+// are not read without kubeconfig, or when the runtime passes no pod.
+func podAnnotations(conf *OdlCniConf, k8sArgs *K8sArgs) (map[string]string, error) {
+	if conf.Kubeconfig == "" || k8sArgs.K8S_POD_NAME == "" {
+		return nil, nil
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = apiTimeout
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := clientSet.CoreV1().Pods(string(k8sArgs.K8S_POD_NAMESPACE)).Get(string(k8sArgs.K8S_POD_NAME), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pod.Annotations, nil
+}
+
+// podVlans returns the VLAN and the trunk VLANs of the pod port, the ones of
+// the configuration unless the pod annotations override them
+func podVlans(conf *OdlCniConf, annotations map[string]string) (uint, []uint, error) {
+	vlan, trunk := conf.Vlan, conf.Trunk
+	if value, ok := annotations[VlanAnnotation]; ok {
+		id, err := parseVlan(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid %s annotation: %v", VlanAnnotation, err)
+		}
+		vlan = id
+	}
+	if value, ok := annotations[TrunkAnnotation]; ok {
+		trunk = nil
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			id, err := parseVlan(field)
+			if err != nil {
+				return 0, nil, fmt.Errorf("invalid %s annotation: %v", TrunkAnnotation, err)
+			}
+			trunk = append(trunk, id)
+		}
+	}
+	return vlan, trunk, nil
+}
+
+func parseVlan(value string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateVlan(uint(id)); err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+func validateVlan(id uint) error {
+	if id == 0 || id > maxVlan {
+		return fmt.Errorf("VLAN %d out of 1-%d", id, maxVlan)
+	}
+	return nil
+}
+
+// podBandwidth returns the bandwidth of the pod, the one of the runtime
+// config, or of the pod annotations, nil when it has none
+func podBandwidth(conf *OdlCniConf, annotations map[string]string) (*BandwidthEntry, error) {
+	if conf.RuntimeConfig.Bandwidth != nil {
+		return conf.RuntimeConfig.Bandwidth, nil
+	}
+	ingress, err := parseRate(annotations[IngressBandwidthAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", IngressBandwidthAnnotation, err)
+	}
+	egress, err := parseRate(annotations[EgressBandwidthAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", EgressBandwidthAnnotation, err)
+	}
+	if ingress == 0 && egress == 0 {
+		return nil, nil
+	}
+	return &BandwidthEntry{IngressRate: ingress, EgressRate: egress}, nil
+}
+
+// parseRate parses a rate in bps such as 10M, 0 when unset
+func parseRate(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() <= 0 {
+		return 0, fmt.Errorf("%s is not a positive rate", value)
+	}
+	return quantity.Value(), nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2017 Kontron Canada and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const (
+	// ICMPv6 neighbor advertisement
+	icmpv6NeighborAdvert = 136
+
+	// Override flag of the neighbor advertisement
+	naFlagOverride = 0x20
+
+	// Target link-layer address option
+	ndOptTargetLLAddr = 2
+
+	// Hop limit required by neighbor discovery
+	ndHopLimit = 255
+)
+
+// sendUnsolicitedNA advertises the IPv6 address and the mac address of the
+// interface to all the nodes of the link, the IPv6 counterpart of a
+// gratuitous ARP. It must run in the netns of the interface.
+func sendUnsolicitedNA(ip net.IP, iface *net.Interface) error {
+	if ip.To4() != nil || ip.To16() == nil {
+		return fmt.Errorf("%s is not an IPv6 address", ip)
+	}
+	if len(iface.HardwareAddr) != 6 {
+		return fmt.Errorf("interface %s has no ethernet address", iface.Name)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET6, syscall.SOCK_RAW, syscall.IPPROTO_ICMPV6)
+	if err != nil {
+		return fmt.Errorf("failed to open an ICMPv6 socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, ndHopLimit); err != nil {
+		return fmt.Errorf("failed to set the hop limit: %v", err)
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, iface.Index); err != nil {
+		return fmt.Errorf("failed to set the interface: %v", err)
+	}
+	src := &syscall.SockaddrInet6{}
+	copy(src.Addr[:], ip.To16())
+	if err := syscall.Bind(fd, src); err != nil {
+		return fmt.Errorf("failed to bind %s: %v", ip, err)
+	}
+
+	// type, code, checksum computed by the kernel, flags and reserved,
+	// target address, then the target link-layer address option
+	msg := make([]byte, 32)
+	msg[0] = icmpv6NeighborAdvert
+	msg[4] = naFlagOverride
+	copy(msg[8:24], ip.To16())
+	msg[24] = ndOptTargetLLAddr
+	msg[25] = 1
+	copy(msg[26:32], iface.HardwareAddr)
+
+	// all-nodes multicast address
+	dst := &syscall.SockaddrInet6{ZoneId: uint32(iface.Index)}
+	copy(dst.Addr[:], net.IPv6linklocalallnodes)
+	if err := syscall.Sendto(fd, msg, 0, dst); err != nil {
+		return fmt.Errorf("failed to send the neighbor advertisement: %v", err)
+	}
+	return nil
+}
@@ -32,6 +32,7 @@ const (
 	insertOpr = "insert"
 	deleteOpr = "delete"
 	mutateOpr = "mutate"
+	updateOpr = "update"
 )
 
 // OVS driver state
@@ -212,7 +213,7 @@ func (self *OvsDriver) CreateBridge(bridgeName string) error {
 	if err != nil {
 		return fmt.Errorf("Error while creating ovs bridge %v", err)
 	}
-	return self.CreatePort(bridgeName, "internal", 0, nil)
+	return self.CreatePort(bridgeName, "internal", 0, nil, nil)
 }
 
 // Delete a bridge from ovs instance
@@ -257,8 +258,10 @@ func (self *OvsDriver) DeleteBridge(bridgeName string) error {
 	return self.OvsdbTransact(operations)
 }
 
-// Create port in OVS bridge
-func (self *OvsDriver) CreatePort(intfName string, intfType string, vlanTag uint, extIDs map[string]string) error {
+// Create port in OVS bridge. The port is an access port of vlanTag, a trunk
+// port of the trunks VLANs, or a trunk port whose untagged traffic is on
+// vlanTag when both are set.
+func (self *OvsDriver) CreatePort(intfName string, intfType string, vlanTag uint, trunks []uint, extIDs map[string]string) error {
 	//check if port already created
 	if self.IsPortNamePresent(intfName) {
 		return nil
@@ -290,10 +293,22 @@ func (self *OvsDriver) CreatePort(intfName string, intfType string, vlanTag uint
 
 	// insert row in Port table
 	port["name"] = intfName
-	if vlanTag != 0 {
+	switch {
+	case len(trunks) != 0 && vlanTag != 0:
+		port["vlan_mode"] = "native-untagged"
+		port["tag"] = vlanTag
+	case len(trunks) != 0:
+		port["vlan_mode"] = "trunk"
+	case vlanTag != 0:
 		port["vlan_mode"] = "access"
 		port["tag"] = vlanTag
 	}
+	if len(trunks) != 0 {
+		port["trunks"], err = libovsdb.NewOvsSet(trunks)
+		if err != nil {
+			return fmt.Errorf("Error at trunks, %v", err)
+		}
+	}
 
 	port["interfaces"], err = libovsdb.NewOvsSet(intfUuid)
 	if err != nil {
@@ -529,6 +544,194 @@ func (self *OvsDriver) GetPortNameByExternalId(extIdKey string, extIdValue strin
 	return ""
 }
 
+// Get the name of the port having all the given externalIds, an empty value
+// matching a missing externalId. Returns "" if there is none.
+func (self *OvsDriver) GetPortNameByExternalIds(extIDs map[string]string) string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, row := range self.ovsdbCache["Port"] {
+		if hasExternalIds(row, extIDs) {
+			return row.Fields["name"].(string)
+		}
+	}
+	return ""
+}
+
+// Get the externalIds of a port, nil if the port does not exist
+func (self *OvsDriver) GetPortExternalIds(intfName string) map[string]string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, row := range self.ovsdbCache["Port"] {
+		if name, ok := row.Fields["name"]; !ok || name != intfName {
+			continue
+		}
+		return externalIds(row)
+	}
+	return nil
+}
+
+// The external_ids column of a row as a map
+func externalIds(row libovsdb.Row) map[string]string {
+	extIDs := make(map[string]string)
+	if ovsMap, ok := row.Fields["external_ids"].(libovsdb.OvsMap); ok {
+		for key, value := range ovsMap.GoMap {
+			extIDs[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return extIDs
+}
+
+// Check the row has the external ids, an empty value matching a missing one
+func hasExternalIds(row libovsdb.Row, extIDs map[string]string) bool {
+	rowExtIDs := externalIds(row)
+	for key, value := range extIDs {
+		if rowExtIDs[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Limit the rate of the traffic received from the interface, in kbps, with
+// a burst in kb; a rate of 0 removes the limit
+func (self *OvsDriver) SetIngressPolicing(intfName string, rate, burst int64) error {
+	intf := map[string]interface{}{
+		"ingress_policing_rate":  rate,
+		"ingress_policing_burst": burst,
+	}
+	condition := libovsdb.NewCondition("name", "==", intfName)
+	intfOp := libovsdb.Operation{
+		Op:    updateOpr,
+		Table: "Interface",
+		Row:   intf,
+		Where: []interface{}{condition},
+	}
+	return self.OvsdbTransact([]libovsdb.Operation{intfOp})
+}
+
+// Limit the rate of the traffic sent through the port, in bps, with a burst
+// in bits, by a linux-htb QoS and its queue. They hold extIDs, and replace
+// the QoS and queues holding them; a rate of 0 removes the limit.
+func (self *OvsDriver) SetPortQoS(portName string, maxRate, burst int64, extIDs map[string]string) error {
+	var operations []libovsdb.Operation
+	port := make(map[string]interface{})
+	if maxRate == 0 {
+		port["qos"], _ = libovsdb.NewOvsSet([]libovsdb.UUID{})
+	} else {
+		qosUuidStr := "odlQos"
+		queueUuidStr := "odlQueue"
+		extIdsMap, _ := libovsdb.NewOvsMap(extIDs)
+
+		config := map[string]string{"max-rate": fmt.Sprint(maxRate)}
+		if burst != 0 {
+			config["burst"] = fmt.Sprint(burst)
+		}
+		queue := make(map[string]interface{})
+		queue["other_config"], _ = libovsdb.NewOvsMap(config)
+		queue["external_ids"] = extIdsMap
+
+		qos := make(map[string]interface{})
+		qos["type"] = "linux-htb"
+		qos["other_config"], _ = libovsdb.NewOvsMap(map[string]string{"max-rate": fmt.Sprint(maxRate)})
+		qos["queues"], _ = libovsdb.NewOvsMap(map[int]libovsdb.UUID{0: {GoUUID: queueUuidStr}})
+		qos["external_ids"] = extIdsMap
+
+		operations = append(operations,
+			libovsdb.Operation{
+				Op:       insertOpr,
+				Table:    "Queue",
+				Row:      queue,
+				UUIDName: queueUuidStr,
+			},
+			libovsdb.Operation{
+				Op:       insertOpr,
+				Table:    "QoS",
+				Row:      qos,
+				UUIDName: qosUuidStr,
+			})
+		port["qos"] = libovsdb.UUID{GoUUID: qosUuidStr}
+	}
+
+	condition := libovsdb.NewCondition("name", "==", portName)
+	operations = append(operations, libovsdb.Operation{
+		Op:    updateOpr,
+		Table: "Port",
+		Row:   port,
+		Where: []interface{}{condition},
+	})
+	operations = append(operations, self.deleteQoSOps(extIDs)...)
+	return self.OvsdbTransact(operations)
+}
+
+// Delete the QoS and queues holding the external ids, which no port uses
+// any more
+func (self *OvsDriver) DeleteQoSByExternalIds(extIDs map[string]string) error {
+	operations := self.deleteQoSOps(extIDs)
+	if len(operations) == 0 {
+		return nil
+	}
+	return self.OvsdbTransact(operations)
+}
+
+// QoS and Queue are root tables, their rows must be deleted explicitly
+func (self *OvsDriver) deleteQoSOps(extIDs map[string]string) []libovsdb.Operation {
+	if len(extIDs) == 0 {
+		return nil
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	var operations []libovsdb.Operation
+	for _, table := range []string{"QoS", "Queue"} {
+		for uuid, row := range self.ovsdbCache[table] {
+			if !hasExternalIds(row, extIDs) {
+				continue
+			}
+			condition := libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: uuid})
+			operations = append(operations, libovsdb.Operation{
+				Op:    deleteOpr,
+				Table: table,
+				Where: []interface{}{condition},
+			})
+		}
+	}
+	return operations
+}
+
+// Check if the port is attached to the driver bridge
+func (self *OvsDriver) IsPortOnBridge(intfName string) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	portUuid := ""
+	for uuid, row := range self.ovsdbCache["Port"] {
+		if name, ok := row.Fields["name"]; ok && name == intfName {
+			portUuid = uuid
+			break
+		}
+	}
+	if portUuid == "" {
+		return false
+	}
+	for _, row := range self.ovsdbCache["Bridge"] {
+		if name, ok := row.Fields["name"]; !ok || name != self.OvsBridgeName {
+			continue
+		}
+		// A set of a single element is sent as the element itself
+		switch ports := row.Fields["ports"].(type) {
+		case libovsdb.UUID:
+			return ports.GoUUID == portUuid
+		case libovsdb.OvsSet:
+			for _, port := range ports.GoSet {
+				if uuid, ok := port.(libovsdb.UUID); ok && uuid.GoUUID == portUuid {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // Check if the bridge already exists
 func (self *OvsDriver) IsBridgePresent(bridgeName string) bool {
 	self.lock.RLock()
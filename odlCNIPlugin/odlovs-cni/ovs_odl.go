@@ -9,17 +9,18 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"runtime"
 
 	"os"
-	"strings"
 	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
-	"github.com/containernetworking/cni/pkg/types/current"
+	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
 	"github.com/containernetworking/plugins/pkg/ip"
 	"github.com/containernetworking/plugins/pkg/ipam"
@@ -28,9 +29,19 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
+// External ids of the pod ports
 const (
-	mtu     = 1400
-	netmask = "/24"
+	// <namespace>:<name> of the pod
+	ifaceIDKey      = "iface-id"
+	clusterIDKey    = "cluster-id"
+	podNamespaceKey = "pod-namespace"
+	podNameKey      = "pod-name"
+	podUIDKey       = "pod-uid"
+	containerIDKey  = "container-id"
+	ifNameKey       = "ifname"
+	ipAddressKey    = "ip-address"
+	ipAddress6Key   = "ip-address6"
+	attachedMacKey  = "attached-mac"
 )
 
 func cmdAdd(args *skel.CmdArgs) error {
@@ -42,9 +53,24 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return fmt.Errorf("Error while parse conf: %v", err)
 	}
 
-	if ovsConfig.ClusterID == "" {
-		ovsConfig.ClusterID = "00000000-0000-0000-0000-000000000001"
+	k8sArgs := K8sArgs{}
+	err = types.LoadArgs(args.Args, &k8sArgs)
+	if err != nil {
+		return fmt.Errorf("Error while parsing k8s arguments, %v", err)
+	}
+	annotations, err := podAnnotations(&ovsConfig, &k8sArgs)
+	if err != nil {
+		return fmt.Errorf("Error reading the pod annotations, %v", err)
+	}
+	vlan, trunk, err := podVlans(&ovsConfig, annotations)
+	if err != nil {
+		return fmt.Errorf("Error getting the pod VLANs, %v", err)
+	}
+	bandwidth, err := podBandwidth(&ovsConfig, annotations)
+	if err != nil {
+		return fmt.Errorf("Error getting the pod bandwidth, %v", err)
 	}
+
 	// Get Open vSwitch driver
 	ovsDriver := NewOvsDriver(ovsConfig.OvsBridge)
 	// sleep to make sure the bridge link has been created
@@ -68,13 +94,22 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 	defer contNetNS.Close()
 
+	mtu, err := podMTU(&ovsConfig)
+	if err != nil {
+		return fmt.Errorf("Error getting the pod MTU, %v", err)
+	}
+	hostVethName := ""
+	if ovsConfig.HostVethPrefix != "" {
+		hostVethName = hostVethNameOf(ovsConfig.HostVethPrefix, args.ContainerID, args.IfName)
+	}
+
 	// Setup the contNetNS tap and set container interface
 	contIface := &current.Interface{}
 	hostIface := &current.Interface{}
 
 	err = contNetNS.Do(func(hostNS ns.NetNS) error {
 		// create the veth pair in the container and move host end into host netns
-		hostVeth, containerVeth, err := ip.SetupVeth(args.IfName, mtu, hostNS)
+		hostVeth, containerVeth, err := ip.SetupVethWithName(args.IfName, hostVethName, mtu, "", hostNS)
 		if err != nil {
 			return fmt.Errorf("Error Setup Veth, %v", err)
 		}
@@ -109,6 +144,9 @@ func cmdAdd(args *skel.CmdArgs) error {
 
 	// Configure the container hardware and IP addresses
 	result.Interfaces = []*current.Interface{hostIface, contIface}
+	for _, ipc := range result.IPs {
+		ipc.Interface = current.Int(1)
+	}
 	if err := contNetNS.Do(func(_ ns.NetNS) error {
 		contIface, err := net.InterfaceByName(args.IfName)
 		if err != nil {
@@ -123,85 +161,294 @@ func cmdAdd(args *skel.CmdArgs) error {
 		// Just for now send arp to all other ports. Will delete this once ctlr push
 		// flow rules to the bridge.
 		for _, ipc := range result.IPs {
-			if ipc.Version == "4" {
+			if ipc.Address.IP.To4() != nil {
 				_ = arping.GratuitousArpOverIface(ipc.Address.IP, *contIface)
-				// Set the container ip-address as external-Id
-				extIDs["ip-address"] = ipc.Address.IP.String()
+			} else {
+				_ = sendUnsolicitedNA(ipc.Address.IP, contIface)
 			}
 		}
+		// Set the container ip-addresses as external-Ids
+		for key, value := range ipAddressExternalIds(result.IPs) {
+			extIDs[key] = value
+		}
 		// Set the container mac address
-		extIDs["attached-mac"] = contIface.HardwareAddr.String()
+		extIDs[attachedMacKey] = contIface.HardwareAddr.String()
 		return nil
 	}); err != nil {
 		return fmt.Errorf("Error configure container Hardware And IP Addresses, %v", err)
 	}
 
-	k8sArgs := K8sArgs{}
-	err = types.LoadArgs(args.Args, &k8sArgs)
-	if err != nil {
-		return fmt.Errorf("Error while parsing k8s arguments, ", err)
+	for key, value := range podExternalIds(&ovsConfig, args, &k8sArgs) {
+		extIDs[key] = value
 	}
-	extIDs["iface-id"] = fmt.Sprintf("%s:%s", ovsConfig.ClusterID, k8sArgs.K8S_POD_NAME)
-	err = ovsDriver.CreatePort(hostIface.Name, "", 0, extIDs)
+	err = ovsDriver.CreatePort(hostIface.Name, "", vlan, trunk, extIDs)
 	if err != nil {
 		return fmt.Errorf("Error adding created pods veth to ovs bridge %v", err)
 	}
+	if bandwidth != nil {
+		if err := setPortBandwidth(ovsDriver, hostIface.Name, bandwidth, qosExternalIds(args)); err != nil {
+			return fmt.Errorf("Error limiting the pod bandwidth, %v", err)
+		}
+	}
 
 	// Add the public interface to ovs bridge
 	if ovsConfig.ExternalIntf != "" {
-		err := ovsDriver.CreatePort(ovsConfig.ExternalIntf, "system", 0, nil)
+		err := ovsDriver.CreatePort(ovsConfig.ExternalIntf, "system", 0, nil, nil)
 		if err != nil {
 			return fmt.Errorf("Error Adding external net interface %v", err)
 		}
 	}
-	// Set the default gw to the ovsbrk8s intf
-	link, _ := netlink.LinkByName(ovsConfig.OvsBridge)
-	if link.Attrs().OperState != netlink.OperUp {
-		cidr := result.IPs[0].Gateway.String()
-		if strings.IndexByte(cidr, '/') < 0 {
-			cidr = cidr + netmask
-		}
-		ipNet, err := netlink.ParseIPNet(cidr)
+	// Set the gateways of the pod subnets on the ovsbrk8s intf
+	link, err := netlink.LinkByName(ovsConfig.OvsBridge)
+	if err != nil {
+		return fmt.Errorf("Error getting the bridge link %s, %v", ovsConfig.OvsBridge, err)
+	}
+	if err := setBridgeGateways(link, result.IPs); err != nil {
+		return fmt.Errorf("Error setting the bridge gateways, %v", err)
+	}
+
+	// Pass the result of the previous plugins of the chain on with ours
+	result, err = mergeResult(ovsConfig.PrevResult, result)
+	if err != nil {
+		return fmt.Errorf("Error merging the prevResult, %v", err)
+	}
+	return types.PrintResult(result, ovsConfig.CNIVersion)
+}
+
+// cmdDel removes whatever is left of the pod network, so that it succeeds
+// when the netns, the veth, the OVS port or the IPAM state are already gone,
+// and when it is called again.
+func cmdDel(args *skel.CmdArgs) error {
+	ovsConfig, err := parseOdlCniConf(args.StdinData)
+	if err != nil {
+		return fmt.Errorf("Error while parse conf: %v", err)
+	}
+
+	// Delete the container veth, the host end going with it
+	if args.Netns != "" {
+		err := ns.WithNetNSPath(args.Netns, func(_ ns.NetNS) error {
+			if err := ip.DelLinkByName(args.IfName); err != nil && err != ip.ErrLinkNotFound {
+				return err
+			}
+			return nil
+		})
 		if err != nil {
-			return fmt.Errorf("Error parsing external IPAddress %v", err)
+			if _, ok := err.(ns.NSPathNotExistErr); !ok {
+				return fmt.Errorf("Error deleting the container veth, %v", err)
+			}
+		}
+	}
+
+	// Delete the OVS port of the container, the port being left on the
+	// bridge when the veth went away with the netns
+	ovsDriver := NewOvsDriver(ovsConfig.OvsBridge)
+	prtName := ovsDriver.GetPortNameByExternalIds(map[string]string{
+		containerIDKey: args.ContainerID,
+		ifNameKey:      args.IfName,
+	})
+	if prtName == "" {
+		// Ports created before the container-id was recorded, whose
+		// iface-id was <clusterId>:<podName>
+		k8sArgs := K8sArgs{}
+		if err := types.LoadArgs(args.Args, &k8sArgs); err == nil && k8sArgs.K8S_POD_NAME != "" {
+			prtName = ovsDriver.GetPortNameByExternalIds(map[string]string{
+				ifaceIDKey:     fmt.Sprintf("%s:%s", ovsConfig.ClusterID, k8sArgs.K8S_POD_NAME),
+				containerIDKey: "",
+			})
 		}
-		addr := &netlink.Addr{
-			IPNet: ipNet,
-			Label: "",
-			Flags: 0,
-			Scope: 0,
+	}
+	if prtName != "" {
+		if err := ovsDriver.DeletePortByName(prtName); err != nil {
+			return fmt.Errorf("Error deleting ovs port %s, %v", prtName, err)
 		}
-		netlink.AddrAdd(link, addr)
-		netlink.LinkSetUp(link)
+	}
+	// The QoS of the port is left behind by the port
+	if err := ovsDriver.DeleteQoSByExternalIds(qosExternalIds(args)); err != nil {
+		return fmt.Errorf("Error deleting the QoS of the container, %v", err)
 	}
 
-	return types.PrintResult(result, ovsConfig.CNIVersion)
+	// Release the addresses last, so that they are not reused while the
+	// port is still there
+	return ipam.ExecDel(ovsConfig.IPAM.Type, args.StdinData)
 }
 
-func cmdDel(args *skel.CmdArgs) error {
+func cmdCheck(args *skel.CmdArgs) error {
 	ovsConfig, err := parseOdlCniConf(args.StdinData)
 	if err != nil {
 		return fmt.Errorf("Error while parse conf: %v", err)
 	}
-	if err := ipam.ExecDel(ovsConfig.IPAM.Type, args.StdinData); err != nil {
-		return err
+	if ovsConfig.PrevResult == nil {
+		return fmt.Errorf("Error missing prevResult from the runtime")
 	}
-	if ovsConfig.ClusterID == "" {
-		ovsConfig.ClusterID = "00000000-0000-0000-0000-000000000001"
-        }
-	// Get Open vSwitch driver
+
+	// Let the IPAM plugin check its own state first
+	if err := ipam.ExecCheck(ovsConfig.IPAM.Type, args.StdinData); err != nil {
+		return fmt.Errorf("Error execCheck IPAM plugin, %v", err)
+	}
+
+	result, err := current.NewResultFromResult(ovsConfig.PrevResult)
+	if err != nil {
+		return fmt.Errorf("Error convert the prevResult into current Result, %v", err)
+	}
+
+	// Find the interfaces set up by the ADD and the container IPs
+	// ADD sets them up in a row, host end first
+	var contIface, hostIface *current.Interface
+	var contIPs []*current.IPConfig
+	for idx, iface := range result.Interfaces {
+		if iface.Sandbox != args.Netns || iface.Name != args.IfName {
+			continue
+		}
+		contIface = iface
+		if idx > 0 && result.Interfaces[idx-1].Sandbox == "" {
+			hostIface = result.Interfaces[idx-1]
+		}
+		for _, ipc := range result.IPs {
+			if ipc.Interface != nil && *ipc.Interface == idx {
+				contIPs = append(contIPs, ipc)
+			}
+		}
+	}
+	if contIface == nil {
+		return fmt.Errorf("Error prevResult has no interface %s in netns %s", args.IfName, args.Netns)
+	}
+	if hostIface == nil {
+		return fmt.Errorf("Error prevResult has no host interface")
+	}
+
+	mtu, err := podMTU(&ovsConfig)
+	if err != nil {
+		return fmt.Errorf("Error getting the pod MTU, %v", err)
+	}
+
+	// Check the container veth, its addresses and routes
+	var peerIndex int
+	var contMac string
+	err = ns.WithNetNSPath(args.Netns, func(_ ns.NetNS) error {
+		link, index, err := ip.GetVethPeerIfindex(args.IfName)
+		if err != nil {
+			return err
+		}
+		if link.Attrs().MTU != mtu {
+			return fmt.Errorf("interface %s has mtu %d, expected %d", args.IfName, link.Attrs().MTU, mtu)
+		}
+		peerIndex = index
+		contMac = link.Attrs().HardwareAddr.String()
+		if contIface.Mac != "" && contIface.Mac != contMac {
+			return fmt.Errorf("interface %s has mac %s, expected %s", args.IfName, contMac, contIface.Mac)
+		}
+		if err := ip.ValidateExpectedInterfaceIPs(args.IfName, contIPs); err != nil {
+			return err
+		}
+		return ip.ValidateExpectedRoute(routesWithGateway(result))
+	})
+	if err != nil {
+		return fmt.Errorf("Error checking the container NetNS, %v", err)
+	}
+
+	// Check the host veth is the peer of the container one
+	hostLink, err := netlink.LinkByName(hostIface.Name)
+	if err != nil {
+		return fmt.Errorf("Error getting the host veth %s, %v", hostIface.Name, err)
+	}
+	if hostLink.Attrs().Index != peerIndex {
+		return fmt.Errorf("Error host veth %s is not the peer of container %s", hostIface.Name, args.IfName)
+	}
+
+	// Check the host veth is attached to the bridge with the pod external-ids
 	ovsDriver := NewOvsDriver(ovsConfig.OvsBridge)
+	if !ovsDriver.IsPortOnBridge(hostIface.Name) {
+		return fmt.Errorf("Error port %s is not attached to bridge %s", hostIface.Name, ovsConfig.OvsBridge)
+	}
 	k8sArgs := K8sArgs{}
 	err = types.LoadArgs(args.Args, &k8sArgs)
 	if err != nil {
-		return fmt.Errorf("Error while parsing k8s arguments, ", err)
+		return fmt.Errorf("Error while parsing k8s arguments, %v", err)
+	}
+	extIDs := ovsDriver.GetPortExternalIds(hostIface.Name)
+	expected := podExternalIds(&ovsConfig, args, &k8sArgs)
+	expected[attachedMacKey] = contMac
+	for key, value := range ipAddressExternalIds(contIPs) {
+		expected[key] = value
+	}
+	for key, value := range expected {
+		if extIDs[key] != value {
+			return fmt.Errorf("Error port %s has %s %q, expected %q", hostIface.Name, key, extIDs[key], value)
+		}
+	}
+	return nil
+}
+
+// podExternalIds returns the external ids identifying the pod and the
+// container interface of a port
+func podExternalIds(conf *OdlCniConf, args *skel.CmdArgs, k8sArgs *K8sArgs) map[string]string {
+	extIDs := map[string]string{
+		ifaceIDKey:      fmt.Sprintf("%s:%s", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME),
+		clusterIDKey:    conf.ClusterID,
+		podNamespaceKey: string(k8sArgs.K8S_POD_NAMESPACE),
+		podNameKey:      string(k8sArgs.K8S_POD_NAME),
+		containerIDKey:  args.ContainerID,
+		ifNameKey:       args.IfName,
+	}
+	// Not passed by every runtime
+	if k8sArgs.K8S_POD_UID != "" {
+		extIDs[podUIDKey] = string(k8sArgs.K8S_POD_UID)
+	}
+	return extIDs
+}
+
+// ipAddressExternalIds returns the ip-address external ids of the addresses,
+// the first address of each IP family being recorded
+func ipAddressExternalIds(ips []*current.IPConfig) map[string]string {
+	extIDs := make(map[string]string)
+	for _, ipc := range ips {
+		key := ipAddressKey
+		if ipc.Address.IP.To4() == nil {
+			key = ipAddress6Key
+		}
+		if _, ok := extIDs[key]; !ok {
+			extIDs[key] = ipc.Address.IP.String()
+		}
+	}
+	return extIDs
+}
+
+// qosExternalIds returns the external ids of the QoS and queue of a port
+func qosExternalIds(args *skel.CmdArgs) map[string]string {
+	return map[string]string{
+		containerIDKey: args.ContainerID,
+		ifNameKey:      args.IfName,
 	}
-	prtName := ovsDriver.GetPortNameByExternalId("iface-id", fmt.Sprintf("%s:%s", ovsConfig.ClusterID, k8sArgs.K8S_POD_NAME))
-	return ovsDriver.DeletePortByName(prtName)
+}
+
+// setPortBandwidth limits the traffic sent by the pod, which enters OVS
+// through the interface of the port, by ingress policing, and the traffic
+// to the pod, which leaves OVS through the port, by a QoS
+func setPortBandwidth(ovsDriver *OvsDriver, portName string, bw *BandwidthEntry, extIDs map[string]string) error {
+	rate, burst := policing(bw)
+	if err := ovsDriver.SetIngressPolicing(portName, rate, burst); err != nil {
+		return err
+	}
+	return ovsDriver.SetPortQoS(portName, bw.IngressRate, bw.IngressBurst, extIDs)
+}
+
+// policing returns the ingress policing rate and burst of the egress
+// bandwidth, in kbps and kb. They are rounded up, since a rate of 0 means no
+// limit to OVS
+func policing(bw *BandwidthEntry) (rate, burst int64) {
+	rate, burst = kilo(bw.EgressRate), kilo(bw.EgressBurst)
+	if burst == 0 {
+		burst = rate / 10
+	}
+	return rate, burst
+}
+
+func kilo(value int64) int64 {
+	return (value + 999) / 1000
 }
 
 func main() {
-	skel.PluginMain(cmdAdd, cmdDel, version.All)
+	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, version.All, "ODL OVS CNI plugin")
 }
 
 // ConfigureIface takes the result of IPAM plugin and
@@ -220,7 +467,6 @@ func ConfigureIface(ifName string, res *current.Result) error {
 		return fmt.Errorf("failed to set %q UP: %v", ifName, err)
 	}
 
-	var v4gw, v6gw net.IP
 	for _, ipc := range res.IPs {
 		if ipc.Interface == nil {
 			// set the IPConfig to the container Intf
@@ -235,7 +481,159 @@ func ConfigureIface(ifName string, res *current.Result) error {
 		if err = netlink.AddrAdd(link, addr); err != nil {
 			return fmt.Errorf("failed to add IP addr %v", err)
 		}
+	}
+
+	ip.SettleAddresses(ifName, 10)
+
+	// Add the gateway route
+	for _, r := range routesWithGateway(res) {
+		if err = ip.AddRoute(&r.Dst, r.GW, link); err != nil {
+			if !os.IsExist(err) {
+				return fmt.Errorf("failed to add route %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// setBridgeGateways sets the gateway of the first address of each IP family
+// on the bridge internal port, and sets it up. The gateways already set by
+// the previous ADDs are kept.
+func setBridgeGateways(link netlink.Link, ips []*current.IPConfig) error {
+	families := make(map[int]bool)
+	for _, ipc := range ips {
+		if ipc.Gateway == nil {
+			continue
+		}
+		family := netlink.FAMILY_V4
+		if ipc.Gateway.To4() == nil {
+			family = netlink.FAMILY_V6
+		}
+		if families[family] {
+			continue
+		}
+		families[family] = true
+
+		addrs, err := netlink.AddrList(link, family)
+		if err != nil {
+			return fmt.Errorf("failed to list the addresses of %q: %v", link.Attrs().Name, err)
+		}
+		present := false
+		for _, addr := range addrs {
+			if addr.IP.Equal(ipc.Gateway) {
+				present = true
+				break
+			}
+		}
+		if present {
+			continue
+		}
+		// The gateway is on the subnet of the pod address
+		addr := &netlink.Addr{IPNet: &net.IPNet{IP: ipc.Gateway, Mask: ipc.Address.Mask}, Label: ""}
+		if err := netlink.AddrAdd(link, addr); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("failed to add gateway %v to %q: %v", ipc.Gateway, link.Attrs().Name, err)
+		}
+	}
+	if len(families) == 0 {
+		return fmt.Errorf("IPAM plugin returned no gateway")
+	}
+
+	if link.Attrs().OperState != netlink.OperUp {
+		if err := netlink.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to set %q UP: %v", link.Attrs().Name, err)
+		}
+	}
+	return nil
+}
+
+// podMTU returns the MTU of the pod veths: the configured one, or the one of
+// the external interface minus the encapsulation overhead with autoMtu
+func podMTU(conf *OdlCniConf) (int, error) {
+	if !conf.AutoMTU {
+		if conf.MTU == 0 {
+			return DefaultMTU, nil
+		}
+		return conf.MTU, nil
+	}
 
+	var link netlink.Link
+	var err error
+	if conf.ExternalIntf != "" {
+		link, err = netlink.LinkByName(conf.ExternalIntf)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lookup %q: %v", conf.ExternalIntf, err)
+		}
+	} else {
+		// The interface of the default route
+		routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list the routes: %v", err)
+		}
+		for _, route := range routes {
+			if route.Dst == nil && route.LinkIndex > 0 {
+				link, err = netlink.LinkByIndex(route.LinkIndex)
+				if err != nil {
+					return 0, fmt.Errorf("failed to lookup the default route interface: %v", err)
+				}
+				break
+			}
+		}
+		if link == nil {
+			return 0, fmt.Errorf("no default route to detect the MTU from")
+		}
+	}
+
+	mtu := link.Attrs().MTU - encapOverhead[conf.Encap]
+	// A configured MTU caps the detected one
+	if conf.MTU != 0 && conf.MTU < mtu {
+		mtu = conf.MTU
+	}
+	return mtu, nil
+}
+
+// hostVethNameOf derives the host veth name of a container interface from
+// the container ID, so that it is the same on every ADD
+func hostVethNameOf(prefix, containerID, ifName string) string {
+	sum := sha1.Sum([]byte(containerID + ":" + ifName))
+	name := prefix + hex.EncodeToString(sum[:])
+	return name[:maxIfNameLen]
+}
+
+// mergeResult appends the interfaces, addresses and routes of the result to
+// the ones of prevResult, the result of the previous plugins of the chain
+func mergeResult(prevResult types.Result, res *current.Result) (*current.Result, error) {
+	if prevResult == nil {
+		return res, nil
+	}
+	merged, err := current.NewResultFromResult(prevResult)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := len(merged.Interfaces)
+	merged.Interfaces = append(merged.Interfaces, res.Interfaces...)
+	for _, ipc := range res.IPs {
+		if ipc.Interface != nil {
+			ipc.Interface = current.Int(*ipc.Interface + offset)
+		}
+		merged.IPs = append(merged.IPs, ipc)
+	}
+	merged.Routes = append(merged.Routes, res.Routes...)
+	dns := merged.DNS
+	if len(dns.Nameservers) == 0 && dns.Domain == "" && len(dns.Search) == 0 && len(dns.Options) == 0 {
+		merged.DNS = res.DNS
+	}
+	return merged, nil
+}
+
+// routesWithGateway returns the routes of the result, the ones without a
+// gateway going through the gateway of their IP family
+func routesWithGateway(res *current.Result) []*types.Route {
+	var v4gw, v6gw net.IP
+	for _, ipc := range res.IPs {
+		if ipc.Gateway == nil {
+			continue
+		}
 		gwIsV4 := ipc.Gateway.To4() != nil
 		if gwIsV4 && v4gw == nil {
 			v4gw = ipc.Gateway
@@ -244,24 +642,17 @@ func ConfigureIface(ifName string, res *current.Result) error {
 		}
 	}
 
-	ip.SettleAddresses(ifName, 10)
-
-	// Add the gateway route
+	routes := make([]*types.Route, 0, len(res.Routes))
 	for _, r := range res.Routes {
-		routeIsV4 := r.Dst.IP.To4() != nil
-		gw := r.GW
-		if gw == nil {
-			if routeIsV4 && v4gw != nil {
-				gw = v4gw
-			} else if !routeIsV4 && v6gw != nil {
-				gw = v6gw
-			}
-		}
-		if err = ip.AddRoute(&r.Dst, gw, link); err != nil {
-			if !os.IsExist(err) {
-				return fmt.Errorf("failed to add route %v", err)
+		route := *r
+		if route.GW == nil {
+			if route.Dst.IP.To4() != nil {
+				route.GW = v4gw
+			} else {
+				route.GW = v6gw
 			}
 		}
+		routes = append(routes, &route)
 	}
-	return nil
+	return routes
 }
@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2017 Kontron Canada and others.  All rights reserved.
+ *
+ * This program and the accompanying materials are made available under the
+ * terms of the Eclipse Public License v1.0 which accompanies this distribution,
+ * and is available at http://www.eclipse.org/legal/epl-v10.html
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+// prevResults are the results of a previous plugin of the chain, with one
+// interface, one address on it and a default route, in each CNI version.
+var prevResults = map[string]string{
+	"0.3.1": `{
+		"cniVersion": "0.3.1",
+		"interfaces": [{"name": "eth0", "sandbox": "/var/run/netns/pod"}],
+		"ips": [{"version": "4", "address": "10.1.0.2/24", "gateway": "10.1.0.1", "interface": 0}],
+		"routes": [{"dst": "0.0.0.0/0"}]
+	}`,
+	"0.4.0": `{
+		"cniVersion": "0.4.0",
+		"interfaces": [{"name": "eth0", "sandbox": "/var/run/netns/pod"}],
+		"ips": [{"version": "4", "address": "10.1.0.2/24", "gateway": "10.1.0.1", "interface": 0}],
+		"routes": [{"dst": "0.0.0.0/0"}]
+	}`,
+	"1.0.0": `{
+		"cniVersion": "1.0.0",
+		"interfaces": [{"name": "eth0", "sandbox": "/var/run/netns/pod"}],
+		"ips": [{"address": "10.1.0.2/24", "gateway": "10.1.0.1", "interface": 0}],
+		"routes": [{"dst": "0.0.0.0/0"}]
+	}`,
+}
+
+// ovsResult is the result of the plugin itself: the host veth, the pod
+// interface and its address, which is on the second interface.
+func ovsResult(t *testing.T) *current.Result {
+	_, ipNet, err := net.ParseCIDR("10.11.1.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipNet.IP = net.ParseIP("10.11.1.2").To4()
+	_, dst, err := net.ParseCIDR("10.11.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		Interfaces: []*current.Interface{
+			{Name: "veth1234"},
+			{Name: "eth1", Sandbox: "/var/run/netns/pod"},
+		},
+		IPs: []*current.IPConfig{
+			{Address: *ipNet, Gateway: net.ParseIP("10.11.1.1"), Interface: current.Int(1)},
+		},
+		Routes: []*types.Route{{Dst: *dst}},
+	}
+}
+
+// printedResult is the result as written on stdout, in any CNI version.
+type printedResult struct {
+	CNIVersion string `json:"cniVersion"`
+	Interfaces []struct {
+		Name string `json:"name"`
+	} `json:"interfaces"`
+	IPs []struct {
+		Version   string `json:"version"`
+		Address   string `json:"address"`
+		Interface *int   `json:"interface"`
+	} `json:"ips"`
+	Routes []struct {
+		Dst string `json:"dst"`
+	} `json:"routes"`
+}
+
+func TestMergeResult(t *testing.T) {
+	for _, cniVersion := range []string{"0.3.1", "0.4.0", "1.0.0"} {
+		t.Run(cniVersion, func(t *testing.T) {
+			stdin := `{
+				"cniVersion": "` + cniVersion + `",
+				"name": "odl-net",
+				"type": "odlovs-cni",
+				"prevResult": ` + prevResults[cniVersion] + `
+			}`
+			conf, err := parseOdlCniConf([]byte(stdin))
+			if err != nil {
+				t.Fatalf("parseOdlCniConf() = %v", err)
+			}
+			if conf.PrevResult == nil {
+				t.Fatal("prevResult was not parsed")
+			}
+
+			merged, err := mergeResult(conf.PrevResult, ovsResult(t))
+			if err != nil {
+				t.Fatalf("mergeResult() = %v", err)
+			}
+			versioned, err := merged.GetAsVersion(conf.CNIVersion)
+			if err != nil {
+				t.Fatalf("GetAsVersion(%s) = %v", conf.CNIVersion, err)
+			}
+			js, err := json.Marshal(versioned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var printed printedResult
+			if err := json.Unmarshal(js, &printed); err != nil {
+				t.Fatal(err)
+			}
+
+			if printed.CNIVersion != cniVersion {
+				t.Errorf("printed version %s, expected %s", printed.CNIVersion, cniVersion)
+			}
+			var names []string
+			for _, iface := range printed.Interfaces {
+				names = append(names, iface.Name)
+			}
+			if len(names) != 3 || names[0] != "eth0" || names[1] != "veth1234" || names[2] != "eth1" {
+				t.Errorf("interfaces %v, expected the previous one followed by ours", names)
+			}
+
+			expectedIPs := []struct {
+				address string
+				iface   int
+			}{
+				{"10.1.0.2/24", 0},
+				// our pod interface comes after the previous one
+				{"10.11.1.2/24", 2},
+			}
+			if len(printed.IPs) != len(expectedIPs) {
+				t.Fatalf("ips %s, expected %d", js, len(expectedIPs))
+			}
+			for i, expected := range expectedIPs {
+				ip := printed.IPs[i]
+				if ip.Address != expected.address || ip.Interface == nil || *ip.Interface != expected.iface {
+					t.Errorf("ip %d is %s on %v, expected %s on %d", i, ip.Address, ip.Interface, expected.address, expected.iface)
+				}
+				if cniVersion != "1.0.0" && ip.Version != "4" {
+					t.Errorf("ip %d has version %q, expected \"4\" in %s", i, ip.Version, cniVersion)
+				}
+			}
+
+			if len(printed.Routes) != 2 || printed.Routes[0].Dst != "0.0.0.0/0" || printed.Routes[1].Dst != "10.11.0.0/16" {
+				t.Errorf("routes %+v, expected the previous default route then ours", printed.Routes)
+			}
+		})
+	}
+}
+
+func TestMergeResultOfPrevious(t *testing.T) {
+	tests := []struct {
+		name       string
+		cniVersion string
+		prevResult string
+		// interfaces of the previous and our addresses in the merged result
+		ifaces      []int
+		nameservers []string
+	}{
+		{
+			name:       "address on a later interface",
+			cniVersion: "0.3.1",
+			prevResult: `{
+				"cniVersion": "0.3.1",
+				"interfaces": [{"name": "lo"}, {"name": "eth0", "sandbox": "/var/run/netns/pod"}],
+				"ips": [{"version": "4", "address": "10.1.0.2/24", "interface": 1}]
+			}`,
+			// ours shifted past both previous interfaces, the previous one kept
+			ifaces:      []int{1, 3},
+			nameservers: []string{"10.96.0.10"},
+		},
+		{
+			name:       "dns of the previous plugin",
+			cniVersion: "1.0.0",
+			prevResult: `{
+				"cniVersion": "1.0.0",
+				"interfaces": [{"name": "eth0", "sandbox": "/var/run/netns/pod"}],
+				"ips": [{"address": "10.1.0.2/24", "interface": 0}],
+				"dns": {"nameservers": ["10.1.0.10"], "search": ["example.com"]}
+			}`,
+			ifaces:      []int{0, 2},
+			nameservers: []string{"10.1.0.10"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := parseOdlCniConf([]byte(`{"cniVersion": "` + test.cniVersion + `", "name": "odl-net", "type": "odlovs-cni", "prevResult": ` + test.prevResult + `}`))
+			if err != nil {
+				t.Fatalf("parseOdlCniConf() = %v", err)
+			}
+			res := ovsResult(t)
+			res.DNS = types.DNS{Nameservers: []string{"10.96.0.10"}}
+
+			merged, err := mergeResult(conf.PrevResult, res)
+			if err != nil {
+				t.Fatalf("mergeResult() = %v", err)
+			}
+			var ifaces []int
+			for _, ip := range merged.IPs {
+				if ip.Interface == nil {
+					t.Fatalf("address %s has no interface", ip.Address.String())
+				}
+				ifaces = append(ifaces, *ip.Interface)
+			}
+			if !reflect.DeepEqual(ifaces, test.ifaces) {
+				t.Errorf("addresses on interfaces %v, expected %v", ifaces, test.ifaces)
+			}
+			if !reflect.DeepEqual(merged.DNS.Nameservers, test.nameservers) {
+				t.Errorf("nameservers %v, expected %v", merged.DNS.Nameservers, test.nameservers)
+			}
+		})
+	}
+}
+
+// Without a previous plugin the result is ours unchanged.
+func TestMergeResultWithoutPrevResult(t *testing.T) {
+	conf, err := parseOdlCniConf([]byte(`{"cniVersion": "1.0.0", "name": "odl-net", "type": "odlovs-cni"}`))
+	if err != nil {
+		t.Fatalf("parseOdlCniConf() = %v", err)
+	}
+	res := ovsResult(t)
+	merged, err := mergeResult(conf.PrevResult, res)
+	if err != nil {
+		t.Fatalf("mergeResult() = %v", err)
+	}
+	if merged != res || *merged.IPs[0].Interface != 1 {
+		t.Errorf("mergeResult() changed the result: %+v", merged)
+	}
+}
+
+func TestPolicing(t *testing.T) {
+	tests := []struct {
+		name  string
+		bw    BandwidthEntry
+		rate  int64
+		burst int64
+	}{
+		{name: "unlimited"},
+		{name: "burst from the rate", bw: BandwidthEntry{EgressRate: 10000000}, rate: 10000, burst: 1000},
+		{name: "burst set", bw: BandwidthEntry{EgressRate: 10000000, EgressBurst: 50000}, rate: 10000, burst: 50},
+		// 0 would mean no limit
+		{name: "below 1 kbps", bw: BandwidthEntry{EgressRate: 500, EgressBurst: 10}, rate: 1, burst: 1},
+		{name: "rounded up", bw: BandwidthEntry{EgressRate: 1500}, rate: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, burst := policing(&test.bw)
+			if rate != test.rate || burst != test.burst {
+				t.Errorf("policing(%+v) = %d, %d, expected %d, %d", test.bw, rate, burst, test.rate, test.burst)
+			}
+		})
+	}
+}
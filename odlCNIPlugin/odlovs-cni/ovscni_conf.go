@@ -12,9 +12,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
 	"net"
 )
 
+const (
+	// Default cluster ID, recorded in the cluster-id external id of the pod ports
+	DefaultClusterID = "00000000-0000-0000-0000-000000000001"
+
+	// Default MTU of the pod veths
+	DefaultMTU = 1400
+
+	// Default encapsulation of the pod traffic between the nodes
+	DefaultEncap = "vxlan"
+
+	// Longest interface name
+	maxIfNameLen = 15
+
+	// Longest prefix of the host veth names, leaving 8 characters of the
+	// container ID hash
+	maxHostVethPrefix = maxIfNameLen - 8
+)
+
+// Overhead of the encapsulations over IPv4, removed from the MTU of the
+// external interface when auto-detecting the pod MTU
+var encapOverhead = map[string]int{
+	"vxlan":  50,
+	"geneve": 58,
+}
+
 //Example of the expected json
 //{
 //    "cniVersion":"0.3.0",
@@ -29,6 +55,13 @@ import (
 //    "controller":"192.168.33.1",
 //    "externalIntf":"enp0s9",
 //    "externalIp":"192.168.50.11",
+//    "mtu":1400,
+//    "autoMtu":false,
+//    "encap":"vxlan",
+//    "hostVethPrefix":"veth",
+//    "vlan":100,
+//    "trunk":[200,201],
+//    "kubeconfig":"/etc/kubernetes/kubelet.conf",
 //    "ipam":{
 //        "type":"host-local",
 //        "subnet":"10.11.1.0/24",
@@ -52,6 +85,38 @@ type OdlCniConf struct {
 	ExternalIntf string `json:"externalIntf"`
 	ExternalIp   net.IP `json:"externalIp"`
 	ClusterID    string `json:"clusterId"`
+	// MTU of the pod veths, detected from the MTU of the external
+	// interface, or of the default route one, minus the overhead of the
+	// encapsulation when autoMtu is set
+	MTU     int    `json:"mtu"`
+	AutoMTU bool   `json:"autoMtu"`
+	Encap   string `json:"encap"`
+	// Prefix of the host veth names, which are derived from the container
+	// ID and interface name when set, random otherwise
+	HostVethPrefix string `json:"hostVethPrefix"`
+	// The pod ports are access ports of vlan, trunk ports of the trunk
+	// VLANs, or trunk ports whose untagged traffic is on vlan when both
+	// are set
+	Vlan  uint   `json:"vlan"`
+	Trunk []uint `json:"trunk"`
+	// Kubeconfig used to read the pod annotations overriding vlan and
+	// trunk and limiting the bandwidth. The annotations are silently
+	// ignored when unset, the bandwidth then only coming from the runtime
+	// config
+	Kubeconfig string `json:"kubeconfig"`
+	// Passed by the runtime for the capabilities of the plugin
+	RuntimeConfig struct {
+		Bandwidth *BandwidthEntry `json:"bandwidth,omitempty"`
+	} `json:"runtimeConfig,omitempty"`
+}
+
+// BandwidthEntry is the bandwidth capability of the runtime config, the
+// rates in bps and the bursts in bits
+type BandwidthEntry struct {
+	IngressRate  int64 `json:"ingressRate"`
+	IngressBurst int64 `json:"ingressBurst"`
+	EgressRate   int64 `json:"egressRate"`
+	EgressBurst  int64 `json:"egressBurst"`
 }
 
 // K8sArgs is the CNI_ARGS used by Kubernetes
@@ -59,6 +124,7 @@ type K8sArgs struct {
 	types.CommonArgs
 	K8S_POD_NAME      types.UnmarshallableString
 	K8S_POD_NAMESPACE types.UnmarshallableString
+	K8S_POD_UID       types.UnmarshallableString
 }
 
 // parse odlcni conf
@@ -66,7 +132,11 @@ func parseOdlCniConf(stdin []byte) (OdlCniConf, error) {
 	odlCniConf := OdlCniConf{}
 	err := json.Unmarshal(stdin, &odlCniConf)
 	if err != nil {
-		fmt.Errorf("failed to parse odlcni configurations: %v", err)
+		return odlCniConf, fmt.Errorf("failed to parse odlcni configurations: %v", err)
+	}
+	// The runtime passes the result of the ADD to CHECK
+	if err := version.ParsePrevResult(&odlCniConf.NetConf); err != nil {
+		return odlCniConf, fmt.Errorf("failed to parse prevResult: %v", err)
 	}
 
 	if odlCniConf.OvsBridge == "" {
@@ -78,5 +148,35 @@ func parseOdlCniConf(stdin []byte) (OdlCniConf, error) {
 	if odlCniConf.MgrPort == 0 {
 		odlCniConf.MgrPort = DefaultManagerPort
 	}
+	if odlCniConf.ClusterID == "" {
+		odlCniConf.ClusterID = DefaultClusterID
+	}
+	if odlCniConf.Encap == "" {
+		odlCniConf.Encap = DefaultEncap
+	}
+	if _, ok := encapOverhead[odlCniConf.Encap]; !ok {
+		return odlCniConf, fmt.Errorf("unknown encap %q, expected vxlan or geneve", odlCniConf.Encap)
+	}
+	if odlCniConf.MTU < 0 {
+		return odlCniConf, fmt.Errorf("invalid mtu %d", odlCniConf.MTU)
+	}
+	if len(odlCniConf.HostVethPrefix) > maxHostVethPrefix {
+		return odlCniConf, fmt.Errorf("hostVethPrefix %q is longer than %d characters", odlCniConf.HostVethPrefix, maxHostVethPrefix)
+	}
+	if odlCniConf.Vlan != 0 {
+		if err := validateVlan(odlCniConf.Vlan); err != nil {
+			return odlCniConf, fmt.Errorf("invalid vlan: %v", err)
+		}
+	}
+	for _, id := range odlCniConf.Trunk {
+		if err := validateVlan(id); err != nil {
+			return odlCniConf, fmt.Errorf("invalid trunk: %v", err)
+		}
+	}
+	if bw := odlCniConf.RuntimeConfig.Bandwidth; bw != nil {
+		if bw.IngressRate < 0 || bw.IngressBurst < 0 || bw.EgressRate < 0 || bw.EgressBurst < 0 {
+			return odlCniConf, fmt.Errorf("invalid bandwidth: negative rate or burst")
+		}
+	}
 	return odlCniConf, nil
 }
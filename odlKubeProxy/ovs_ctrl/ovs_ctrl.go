@@ -67,7 +67,8 @@ func (ovsCtrl *OvsController) OnPodUpdate(podUpdate *watchers.PodUpdate) {
 			fallthrough
 		case utils.UPDATE:
 			{
-				Ids, ofPort, err := ovsCtrl.ovsDriver.GetExternalIdsOFportNo("ip-address", podUpdate.Pod.Status.PodIP)
+				Ids, ofPort, err := ovsCtrl.ovsDriver.GetExternalIdsOFportNo(
+					utils.IPAddressKey(net.ParseIP(podUpdate.Pod.Status.PodIP)), podUpdate.Pod.Status.PodIP)
 				if err != nil {
 					log.Debug("Pod Update: %v , %v", err, podUpdate.Pod.Status.PodIP)
 					return
@@ -227,13 +228,13 @@ func (ovsCtrl *OvsController) PacketRcvd(sw *ofctrl.OFSwitch, packet *openflow13
 				endpnt.GetPodIdentifier())
 
 			srcHwMac := net.HardwareAddr{}
-			ofSrcPortNo, _ := ovsCtrl.ovsDriver.GetOfPortNoByExternalId("ip-address", sourceIP.String())
+			ofSrcPortNo, _ := ovsCtrl.ovsDriver.GetOfPortNoByExternalId(utils.IPAddressKey(sourceIP), sourceIP.String())
 			if ofSrcPortNo == 0 {
 				ndIP := ovsCtrl.pods[sourceIP.String()]
 				if ndIP != "" {
 					ofSrcPortNo, _ = ovsCtrl.ovsDriver.GetTunnelPortNoByRemoteIP(ndIP)
 				} else {
-					ofSrcPortNo, _ = ovsCtrl.ovsDriver.GetOfPortNoByExternalId("ip-address", srvIP.String())
+					ofSrcPortNo, _ = ovsCtrl.ovsDriver.GetOfPortNoByExternalId(utils.IPAddressKey(srvIP), srvIP.String())
 					srcHwMac = packet.Data.HWSrc
 					if ofSrcPortNo == 0 {
 						log.Println("Can not find port number")
@@ -245,7 +246,7 @@ func (ovsCtrl *OvsController) PacketRcvd(sw *ofctrl.OFSwitch, packet *openflow13
 			Ids, _ := ovsCtrl.ovsDriver.GetExternalIds("iface-id", endpnt.GetPodIdentifier())
 			macAddress := Ids["attached-mac"]
 			dstHwMac, _ := net.ParseMAC(macAddress.(string))
-			temp := Ids["ip-address"]
+			temp := Ids[utils.IPAddressKey(srvIP)]
 			podIP := net.ParseIP(temp.(string))
 			ovsCtrl.setEndPointFlowRule(ofDestPortNo, podIP,srvIP, endpnt.Ports[0].Protocol, int32(tcpDstPortNo),
 				srv.Ports[0].TargetPort, dstHwMac, sourceIP, ofSrcPortNo, tcpSrcPortNo, srcHwMac)
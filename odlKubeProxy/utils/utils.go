@@ -55,6 +55,16 @@ type EndPointInfo struct {
 	Ports      []PortInfo
 }
 
+// The external id odlovs-cni records the pod address of the IP family in:
+// ip-address for IPv4, ip-address6 for IPv6
+func IPAddressKey(ip net.IP) string {
+	if ip.To4() == nil {
+		return "ip-address6"
+	}
+	return "ip-address"
+}
+
+// The iface-id odlovs-cni sets on the OVS port of the pod
 func (self *EndPointInfo) GetPodIdentifier() string {
 	return self.PodNs + ":" + self.PodName
 }
@@ -21,3 +21,9 @@ type Coe interface {
 	UpdateNode(old, new *v1.Node) error
 	DeleteNode(*v1.Node) error
 }
+
+// Flusher is implemented by backends which may hold back events, so that
+// they can be sent before the watcher exits.
+type Flusher interface {
+	Flush()
+}
@@ -6,22 +6,100 @@ import (
 	"k8s.io/api/core/v1"
 )
 
+// TunnelEndpointAnnotation is the node annotation holding the IP address that
+// OVS should use as the node's tunnel endpoint.
+const TunnelEndpointAnnotation = "coe.opendaylight.org/tunnel-endpoint"
+
 func isNodeUpdated(oldNode *v1.Node, newNode *v1.Node) bool {
 	if oldNode.Spec.PodCIDR != newNode.Spec.PodCIDR {
 		return true
 	}
-	return !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+	if !reflect.DeepEqual(oldNode.Spec.PodCIDRs, newNode.Spec.PodCIDRs) {
+		return true
+	}
+	if !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) {
+		return true
+	}
+	if !isNodeConditionsEqual(oldNode.Status.Conditions, newNode.Status.Conditions) {
+		return true
+	}
+	if !isTaintsEqual(oldNode.Spec.Taints, newNode.Spec.Taints) {
+		return true
+	}
+	if !isStringMapEqual(oldNode.GetLabels(), newNode.GetLabels()) {
+		return true
+	}
+	return oldNode.GetAnnotations()[TunnelEndpointAnnotation] != newNode.GetAnnotations()[TunnelEndpointAnnotation]
+}
+
+// IsNodeReady reports whether the node's Ready condition is true.
+func IsNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isNodeConditionsEqual ignores the heartbeat and transition timestamps, which
+// the kubelet refreshes periodically without any change in the node state.
+func isNodeConditionsEqual(a, b []v1.NodeCondition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Status != b[i].Status || a[i].Reason != b[i].Reason {
+			return false
+		}
+	}
+	return true
+}
+
+func isTaintsEqual(a, b []v1.Taint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].Value != b[i].Value || a[i].Effect != b[i].Effect {
+			return false
+		}
+	}
+	return true
 }
 
 func isPodUpdated(oldPod *v1.Pod, newPod *v1.Pod) bool {
 	if oldPod.Status.PodIP != newPod.Status.PodIP {
 		return true
 	}
+	if len(oldPod.Status.PodIPs) != len(newPod.Status.PodIPs) {
+		return true
+	}
+	for i := range oldPod.Status.PodIPs {
+		if oldPod.Status.PodIPs[i].IP != newPod.Status.PodIPs[i].IP {
+			return true
+		}
+	}
 	if oldPod.Status.HostIP != newPod.Status.HostIP {
 		return true
 	}
+	if oldPod.Spec.NodeName != newPod.Spec.NodeName {
+		return true
+	}
+	if oldPod.Status.Phase != newPod.Status.Phase {
+		return true
+	}
+	if IsPodReady(oldPod) != IsPodReady(newPod) {
+		return true
+	}
+	if !isStringMapEqual(oldPod.GetLabels(), newPod.GetLabels()) {
+		return true
+	}
+	if !isStringMapEqual(oldPod.GetAnnotations(), newPod.GetAnnotations()) {
+		return true
+	}
 	if oldPod.GetName() != newPod.GetName() {
-		return false
+		return true
 	}
 	if oldPod.GetNamespace() != newPod.GetNamespace() {
 		return true
@@ -32,6 +110,29 @@ func isPodUpdated(oldPod *v1.Pod, newPod *v1.Pod) bool {
 	return false
 }
 
+// IsPodReady reports whether the pod's Ready condition is true.
+func IsPodReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isStringMapEqual treats nil and empty maps as equal, unlike reflect.DeepEqual.
+func isStringMapEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
 func isServiceUpdated(oldService *v1.Service, newService *v1.Service) bool {
 	if !reflect.DeepEqual(oldService.Spec.Ports, newService.Spec.Ports) {
 		return true
@@ -0,0 +1,122 @@
+package journal
+
+import (
+	"log"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Backend hands the events to another backend and journals them with its
+// result.
+type Backend struct {
+	backend backends.Coe
+	journal *Journal
+}
+
+// Wrap returns a backend journaling the events handed to backend.
+func Wrap(backend backends.Coe, journal *Journal) *Backend {
+	return &Backend{
+		backend: backend,
+		journal: journal,
+	}
+}
+
+// Use wraps backend with the journal of the configuration, returning backend
+// itself when no journal is configured.
+func Use(backend backends.Coe) backends.Coe {
+	var config Config
+	if err := commands.LoadSection("journal", &config); err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+	if config.File == "" {
+		return backend
+	}
+	journal, err := Open(config.File)
+	if err != nil {
+		log.Fatalln("Unable to open the journal:", err)
+	}
+	log.Println("Journaling the events to", config.File)
+	return Wrap(backend, journal)
+}
+
+func (b *Backend) AddPod(pod *v1.Pod) error {
+	err := b.backend.AddPod(pod)
+	b.record("Pod", Add, &pod.ObjectMeta, pod, err)
+	return err
+}
+
+func (b *Backend) UpdatePod(old, new *v1.Pod) error {
+	err := b.backend.UpdatePod(old, new)
+	b.record("Pod", Update, &new.ObjectMeta, new, err)
+	return err
+}
+
+func (b *Backend) DeletePod(pod *v1.Pod) error {
+	err := b.backend.DeletePod(pod)
+	b.record("Pod", Delete, &pod.ObjectMeta, pod, err)
+	return err
+}
+
+func (b *Backend) AddService(service *v1.Service) error {
+	err := b.backend.AddService(service)
+	b.record("Service", Add, &service.ObjectMeta, service, err)
+	return err
+}
+
+func (b *Backend) UpdateService(old, new *v1.Service) error {
+	err := b.backend.UpdateService(old, new)
+	b.record("Service", Update, &new.ObjectMeta, new, err)
+	return err
+}
+
+func (b *Backend) DeleteService(service *v1.Service) error {
+	err := b.backend.DeleteService(service)
+	b.record("Service", Delete, &service.ObjectMeta, service, err)
+	return err
+}
+
+func (b *Backend) AddEndpoints(endpoints *v1.Endpoints) error {
+	err := b.backend.AddEndpoints(endpoints)
+	b.record("Endpoints", Add, &endpoints.ObjectMeta, endpoints, err)
+	return err
+}
+
+func (b *Backend) UpdateEndpoints(old, new *v1.Endpoints) error {
+	err := b.backend.UpdateEndpoints(old, new)
+	b.record("Endpoints", Update, &new.ObjectMeta, new, err)
+	return err
+}
+
+func (b *Backend) DeleteEndpoints(endpoints *v1.Endpoints) error {
+	err := b.backend.DeleteEndpoints(endpoints)
+	b.record("Endpoints", Delete, &endpoints.ObjectMeta, endpoints, err)
+	return err
+}
+
+func (b *Backend) AddNode(node *v1.Node) error {
+	err := b.backend.AddNode(node)
+	b.record("Node", Add, &node.ObjectMeta, node, err)
+	return err
+}
+
+func (b *Backend) UpdateNode(old, new *v1.Node) error {
+	err := b.backend.UpdateNode(old, new)
+	b.record("Node", Update, &new.ObjectMeta, new, err)
+	return err
+}
+
+func (b *Backend) DeleteNode(node *v1.Node) error {
+	err := b.backend.DeleteNode(node)
+	b.record("Node", Delete, &node.ObjectMeta, node, err)
+	return err
+}
+
+func (b *Backend) record(kind, op string, meta *metav1.ObjectMeta, object interface{}, err error) {
+	if err := b.journal.Record(kind, op, meta, object, err); err != nil {
+		log.Printf("unable to journal %s %s %s/%s: %s\n", op, kind, meta.Namespace, meta.Name, err.Error())
+	}
+}
@@ -0,0 +1,35 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Config is the journal section of the configuration file.
+type Config struct {
+	// File is the journal the events are appended to; the events are not
+	// journaled when it is empty.
+	File string `mapstructure:"file"`
+}
+
+func DefaultConfig() Config {
+	return Config{}
+}
+
+func (c *Config) Validate() error {
+	if c.File == "" {
+		return nil
+	}
+	if info, err := os.Stat(filepath.Dir(c.File)); err != nil || !info.IsDir() {
+		return fmt.Errorf("file: the directory of %s does not exist", c.File)
+	}
+	return nil
+}
+
+func init() {
+	defaults := DefaultConfig()
+	commands.RegisterSection("journal", &defaults)
+}
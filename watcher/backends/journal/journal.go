@@ -0,0 +1,137 @@
+// Package journal records the events the watcher hands to its backend in a
+// local append-only file, and replays them.
+//
+// The journal holds one JSON Entry per line. It keeps a history of what was
+// sent, for audits, and lets `coe replay` drive a backend again, e.g. to
+// rebuild a wiped ODL datastore.
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Operations on the objects.
+const (
+	Add    = "add"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Results of the backend.
+const (
+	Succeeded = "ok"
+	Failed    = "failed"
+)
+
+// Entry is the journal line of an event.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Kind is Pod, Service, Endpoints or Node.
+	Kind            string `json:"kind"`
+	Op              string `json:"op"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid"`
+	ResourceVersion string `json:"resourceVersion"`
+	// Hash is the sha256 of Object, to spot identical events.
+	Hash   string `json:"hash"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Object is the Kubernetes object, after the change for updates.
+	Object json.RawMessage `json:"object"`
+}
+
+// Journal appends entries to a file. It is safe for concurrent use.
+type Journal struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// Open opens the journal at path, creating it if needed.
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: file}, nil
+}
+
+// Record appends the entry of an event handed to the backend, err being
+// what the backend returned.
+func (j *Journal) Record(kind, op string, meta *metav1.ObjectMeta, object interface{}, err error) error {
+	payload, jsonErr := json.Marshal(object)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	hash := sha256.Sum256(payload)
+	entry := Entry{
+		Time:            time.Now().UTC(),
+		Kind:            kind,
+		Op:              op,
+		Namespace:       meta.Namespace,
+		Name:            meta.Name,
+		UID:             string(meta.UID),
+		ResourceVersion: meta.ResourceVersion,
+		Hash:            "sha256:" + hex.EncodeToString(hash[:]),
+		Result:          Succeeded,
+		Object:          payload,
+	}
+	if err != nil {
+		entry.Result = Failed
+		entry.Error = err.Error()
+	}
+	line, jsonErr := json.Marshal(entry)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	_, writeErr := j.file.Write(append(line, '\n'))
+	return writeErr
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.file.Close()
+}
+
+// Read calls fn with every entry of the journal at path, in order. A last
+// line truncated by a crash is ignored.
+func Read(path string, fn func(Entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
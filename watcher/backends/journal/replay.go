@@ -0,0 +1,226 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/api/core/v1"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// BackendFactory creates a backend from the configuration, for replays.
+type BackendFactory func() (backends.Coe, error)
+
+var factories = make(map[string]BackendFactory)
+
+// RegisterBackend makes a backend available to `coe replay --backend name`.
+// Backend packages call it from their init function.
+func RegisterBackend(name string, factory BackendFactory) {
+	if _, ok := factories[name]; ok {
+		panic("backend registered twice: " + name)
+	}
+	factories[name] = factory
+}
+
+// flagKeys maps the command line flags to the configuration keys they
+// override.
+var flagKeys = map[string]string{
+	"journal": "journal.file",
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay the journal",
+	Long: `Drives a backend again with the events of the journal, in order, e.g. to
+rebuild a wiped ODL datastore. Updates are replayed with the previous journaled
+state of the object as the old object.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		commands.BindFlags(cmd, flagKeys)
+		var config Config
+		if err := commands.LoadSection("journal", &config); err != nil {
+			log.Fatalln("Invalid configuration:", err)
+		}
+		if config.File == "" {
+			log.Fatalln("No journal configured, set journal.file or --journal")
+		}
+		since, err := parseSince(cmd.Flag("since").Value.String(), time.Now())
+		if err != nil {
+			log.Fatalln("Invalid --since:", err)
+		}
+		failed, _ := cmd.Flags().GetBool("failed")
+
+		var replay func(Entry, json.RawMessage) error
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(writer, "TIME\tKIND\tOP\tNAME\tRESOURCE VERSION\tRESULT")
+			defer writer.Flush()
+			replay = func(entry Entry, old json.RawMessage) error {
+				name := entry.Name
+				if entry.Namespace != "" {
+					name = entry.Namespace + "/" + name
+				}
+				_, err := fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.Kind, entry.Op, name, entry.ResourceVersion, entry.Result)
+				return err
+			}
+		} else {
+			name := cmd.Flag("backend").Value.String()
+			factory, ok := factories[name]
+			if !ok {
+				log.Fatalf("Unknown backend %q, expected one of %s\n", name, strings.Join(backendNames(), ", "))
+			}
+			backend, err := factory()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if flusher, ok := backend.(backends.Flusher); ok {
+				defer flusher.Flush()
+			}
+			replay = func(entry Entry, old json.RawMessage) error {
+				return apply(backend, entry, old)
+			}
+		}
+
+		count, err := replayJournal(config.File, since, failed, replay)
+		if err != nil {
+			log.Fatalln("Unable to replay the journal:", err)
+		}
+		log.Printf("Replayed %d events\n", count)
+	},
+}
+
+// replayJournal calls replay with the entries journaled at or after since,
+// only the failed ones if failed is set, and the previous state of their
+// object. It returns the number of entries replayed.
+func replayJournal(path string, since time.Time, failed bool, replay func(Entry, json.RawMessage) error) (int, error) {
+	objects := make(map[string]json.RawMessage)
+	count := 0
+	err := Read(path, func(entry Entry) error {
+		key := entry.Kind + "/" + entry.UID
+		old := objects[key]
+		if entry.Op == Delete {
+			delete(objects, key)
+		} else {
+			objects[key] = entry.Object
+		}
+		if entry.Time.Before(since) || (failed && entry.Result != Failed) {
+			return nil
+		}
+		count++
+		return replay(entry, old)
+	})
+	return count, err
+}
+
+// apply hands the entry to the backend, an update without a previous state
+// being replayed as an add. A failure is logged, as the watcher does, and
+// the replay goes on.
+func apply(backend backends.Coe, entry Entry, old json.RawMessage) error {
+	op := entry.Op
+	if op == Update && old == nil {
+		op = Add
+	}
+
+	var err error
+	switch entry.Kind {
+	case "Pod":
+		var object, oldObject v1.Pod
+		if err = decode(entry.Object, old, &object, &oldObject); err == nil {
+			err = dispatch(op, func() error { return backend.AddPod(&object) },
+				func() error { return backend.UpdatePod(&oldObject, &object) },
+				func() error { return backend.DeletePod(&object) })
+		}
+	case "Service":
+		var object, oldObject v1.Service
+		if err = decode(entry.Object, old, &object, &oldObject); err == nil {
+			err = dispatch(op, func() error { return backend.AddService(&object) },
+				func() error { return backend.UpdateService(&oldObject, &object) },
+				func() error { return backend.DeleteService(&object) })
+		}
+	case "Endpoints":
+		var object, oldObject v1.Endpoints
+		if err = decode(entry.Object, old, &object, &oldObject); err == nil {
+			err = dispatch(op, func() error { return backend.AddEndpoints(&object) },
+				func() error { return backend.UpdateEndpoints(&oldObject, &object) },
+				func() error { return backend.DeleteEndpoints(&object) })
+		}
+	case "Node":
+		var object, oldObject v1.Node
+		if err = decode(entry.Object, old, &object, &oldObject); err == nil {
+			err = dispatch(op, func() error { return backend.AddNode(&object) },
+				func() error { return backend.UpdateNode(&oldObject, &object) },
+				func() error { return backend.DeleteNode(&object) })
+		}
+	default:
+		err = fmt.Errorf("unknown kind %q", entry.Kind)
+	}
+	if err != nil {
+		log.Printf("unable to replay %s %s %s/%s: %s\n", entry.Op, entry.Kind, entry.Namespace, entry.Name, err.Error())
+	}
+	return nil
+}
+
+func decode(payload, oldPayload json.RawMessage, object, oldObject interface{}) error {
+	if err := json.Unmarshal(payload, object); err != nil {
+		return err
+	}
+	if oldPayload == nil {
+		return nil
+	}
+	return json.Unmarshal(oldPayload, oldObject)
+}
+
+func dispatch(op string, add, update, del func() error) error {
+	switch op {
+	case Add:
+		return add()
+	case Update:
+		return update()
+	case Delete:
+		return del()
+	}
+	return fmt.Errorf("unknown operation %q", op)
+}
+
+// parseSince reads an RFC 3339 time, or a duration before now.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+	ago, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+	}
+	return now.Add(-ago), nil
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	replayCmd.Flags().String("since", "", "Replay the events journaled since this RFC 3339 time, or this long ago, e.g. 2h (default all)")
+	replayCmd.Flags().String("backend", "odl", "Backend the events are replayed to")
+	replayCmd.Flags().String("journal", "", "Journal file (default journal.file)")
+	replayCmd.Flags().Bool("failed", false, "Replay only the events the backend failed to handle")
+	replayCmd.Flags().Bool("dry-run", false, "List the events instead of replaying them")
+	commands.RootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,149 @@
+package odl
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// batchEntry is a single buffered object, already formatted for RESTCONF.
+type batchEntry struct {
+	uid string
+	js  []byte
+}
+
+// batcher buffers added objects per container and hands them over in bulk
+// once the window has elapsed or a container reached the batch size. This
+// turns the burst of Add events fired by the informers' initial list into
+// a handful of requests instead of one PUT per object.
+type batcher struct {
+	// sending is held from taking buffered entries until they are sent, so
+	// that a deletion cannot overtake the write of the object it deletes
+	sending sync.Mutex
+	lock    sync.Mutex
+	window  time.Duration
+	size    int
+	timer   *time.Timer
+	order   []container
+	pending map[container][]batchEntry
+	send    func(c container, entries []batchEntry)
+}
+
+func newBatcher(window time.Duration, size int, send func(c container, entries []batchEntry)) *batcher {
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	return &batcher{
+		window:  window,
+		size:    size,
+		pending: make(map[container][]batchEntry),
+		send:    send,
+	}
+}
+
+// add buffers the object, replacing an already buffered copy of it.
+func (b *batcher) add(c container, uid string, js []byte) {
+	b.lock.Lock()
+	if b.replaceLocked(c, uid, js) {
+		b.lock.Unlock()
+		return
+	}
+	if _, ok := b.pending[c]; !ok {
+		b.order = append(b.order, c)
+	}
+	b.pending[c] = append(b.pending[c], batchEntry{uid: uid, js: js})
+	full := len(b.pending[c]) >= b.size
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.lock.Unlock()
+
+	if full {
+		b.flushContainer(c)
+	}
+}
+
+// replace updates the buffered copy of the object and reports whether there
+// was one; when there was not the caller has to send the update itself.
+func (b *batcher) replace(c container, uid string, js []byte) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.replaceLocked(c, uid, js)
+}
+
+func (b *batcher) replaceLocked(c container, uid string, js []byte) bool {
+	entries := b.pending[c]
+	for i := range entries {
+		if entries[i].uid == uid {
+			entries[i].js = js
+			return true
+		}
+	}
+	return false
+}
+
+// drop forgets a buffered object, so that a deleted object is not sent
+// after its deletion. It waits for the batch being sent, if any, since it
+// may hold the object.
+func (b *batcher) drop(c container, uid string) {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	entries := b.pending[c]
+	for i := range entries {
+		if entries[i].uid == uid {
+			b.pending[c] = append(entries[:i], entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// flush sends everything that is buffered.
+func (b *batcher) flush() {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+	b.lock.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	order := b.order
+	pending := b.pending
+	b.order = nil
+	b.pending = make(map[container][]batchEntry)
+	b.lock.Unlock()
+
+	for _, c := range order {
+		b.sendChunks(c, pending[c])
+	}
+}
+
+func (b *batcher) flushContainer(c container) {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+	b.lock.Lock()
+	entries := b.pending[c]
+	delete(b.pending, c)
+	for i := range b.order {
+		if b.order[i] == c {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+	b.lock.Unlock()
+
+	b.sendChunks(c, entries)
+}
+
+func (b *batcher) sendChunks(c container, entries []batchEntry) {
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > b.size {
+			n = b.size
+		}
+		log.Printf("Sending %d %s in one batch\n", n, c.list)
+		b.send(c, entries[:n])
+		entries = entries[n:]
+	}
+}
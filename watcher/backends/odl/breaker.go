@@ -0,0 +1,247 @@
+package odl
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+	defaultQueueSize        = 10000
+)
+
+var errCircuitOpen = errors.New("ODL is unavailable, circuit is open")
+
+// request is a RESTCONF request kept around so that it can be sent again.
+type request struct {
+	method      string
+	url         string
+	contentType string
+	body        []byte
+}
+
+// unavailableError is returned when ODL could not be reached or answered
+// with a server error, as opposed to rejecting the request itself.
+type unavailableError struct {
+	err error
+}
+
+func (e unavailableError) Error() string {
+	return e.err.Error()
+}
+
+// queuedError is returned for requests which could not be sent right away
+// and were queued to be sent once ODL is reachable again.
+type queuedError struct {
+	err error
+}
+
+func (e queuedError) Error() string {
+	return "request queued: " + e.err.Error()
+}
+
+func isUnavailable(err error) bool {
+	_, ok := err.(unavailableError)
+	return ok
+}
+
+func isQueued(err error) bool {
+	_, ok := err.(queuedError)
+	return ok
+}
+
+// breaker stops sending requests to ODL once it failed threshold times in a
+// row. Requests are queued meanwhile, ODL is probed every cooldown and the
+// queue is replayed in order once it answers again, after which resync is
+// called to rewrite every object, reconciling the writes which were
+// superseded, dropped or rejected meanwhile. Deletes are never dropped,
+// since the resync only rewrites the objects that still exist. Requests
+// failing while the circuit is still closed are retried after cooldown.
+type breaker struct {
+	lock       sync.Mutex
+	threshold  int
+	cooldown   time.Duration
+	maxQueue   int
+	failures   int
+	open       bool
+	draining   bool
+	retrying   bool
+	overflowed bool
+	// set once the circuit closed again or the queue overflowed
+	needsResync bool
+	queue       []request
+	send        func(request) error
+	probe       func() error
+	resync      func()
+}
+
+func newBreaker(threshold int, cooldown time.Duration, maxQueue int, send func(request) error, probe func() error, resync func()) *breaker {
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	if maxQueue <= 0 {
+		maxQueue = defaultQueueSize
+	}
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		maxQueue:  maxQueue,
+		send:      send,
+		probe:     probe,
+		resync:    resync,
+	}
+}
+
+// do sends the request unless the circuit is open or older requests are
+// still waiting, in which case it is queued behind them to keep the order.
+func (b *breaker) do(r request) error {
+	b.lock.Lock()
+	if b.open || len(b.queue) > 0 {
+		b.enqueueLocked(r)
+		open := b.open
+		b.lock.Unlock()
+		if open {
+			return queuedError{errCircuitOpen}
+		}
+		go b.drain()
+		return queuedError{errors.New("earlier requests are pending")}
+	}
+	b.lock.Unlock()
+
+	err := b.send(r)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if isUnavailable(err) {
+		b.enqueueLocked(r)
+		b.failedLocked()
+		b.retryLocked()
+		return queuedError{err}
+	}
+	b.failures = 0
+	return err
+}
+
+// enqueueLocked queues the request. A PUT or DELETE supersedes any queued
+// PUT or DELETE of the same url, since only the last one matters. Once the
+// queue is full writes are dropped, the resync rewriting them, but a DELETE
+// evicts the oldest queued write instead since nothing would replay it.
+func (b *breaker) enqueueLocked(r request) {
+	if r.method != http.MethodPatch {
+		for i := range b.queue {
+			if b.queue[i].method != http.MethodPatch && b.queue[i].url == r.url {
+				b.queue = append(b.queue[:i], b.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(b.queue) >= b.maxQueue {
+		if !b.overflowed {
+			log.Printf("ODL request queue is full, a resync will follow recovery\n")
+		}
+		b.overflowed = true
+		b.needsResync = true
+		if r.method != http.MethodDelete {
+			return
+		}
+		// deletes are queued past the bound when there is nothing to evict
+		for i := range b.queue {
+			if b.queue[i].method != http.MethodDelete {
+				b.queue = append(b.queue[:i], b.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	b.queue = append(b.queue, r)
+}
+
+func (b *breaker) failedLocked() {
+	b.failures++
+	if b.failures >= b.threshold && !b.open {
+		log.Printf("ODL failed %d times in a row, opening the circuit\n", b.failures)
+		b.open = true
+		go b.recover()
+	}
+}
+
+// retryLocked drains the queue after cooldown unless the circuit is open, in
+// which case recover replays it.
+func (b *breaker) retryLocked() {
+	if b.open || b.retrying {
+		return
+	}
+	b.retrying = true
+	time.AfterFunc(b.cooldown, func() {
+		b.lock.Lock()
+		b.retrying = false
+		b.lock.Unlock()
+		b.drain()
+	})
+}
+
+// recover probes ODL until it answers, then closes the circuit and replays
+// the queued requests.
+func (b *breaker) recover() {
+	for {
+		time.Sleep(b.cooldown)
+		if err := b.probe(); isUnavailable(err) {
+			log.Printf("ODL is still unavailable: %s\n", err.Error())
+			continue
+		}
+		b.lock.Lock()
+		log.Printf("ODL is reachable again, replaying %d queued requests\n", len(b.queue))
+		b.open = false
+		b.failures = 0
+		b.needsResync = true
+		b.lock.Unlock()
+		b.drain()
+		return
+	}
+}
+
+func (b *breaker) drain() {
+	b.lock.Lock()
+	if b.draining {
+		b.lock.Unlock()
+		return
+	}
+	b.draining = true
+	b.lock.Unlock()
+
+	for {
+		b.lock.Lock()
+		if b.open || len(b.queue) == 0 {
+			b.draining = false
+			resync := !b.open && b.needsResync
+			if resync {
+				b.overflowed = false
+				b.needsResync = false
+			}
+			b.lock.Unlock()
+			if resync && b.resync != nil {
+				b.resync()
+			}
+			return
+		}
+		r := b.queue[0]
+		b.queue = b.queue[1:]
+		b.lock.Unlock()
+
+		err := b.send(r)
+		if isUnavailable(err) {
+			b.lock.Lock()
+			b.queue = append([]request{r}, b.queue...)
+			b.failedLocked()
+			b.draining = false
+			b.retryLocked()
+			b.lock.Unlock()
+			return
+		}
+		if err != nil {
+			log.Printf("Dropping queued %s %s: %s\n", r.method, r.url, err.Error())
+		}
+	}
+}
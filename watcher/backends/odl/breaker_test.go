@@ -0,0 +1,189 @@
+package odl
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeODL records the requests sent through a breaker, failing them as
+// unavailable while down is set.
+type fakeODL struct {
+	lock    sync.Mutex
+	down    bool
+	sent    []string
+	resyncs int
+}
+
+func (f *fakeODL) setDown(down bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.down = down
+}
+
+func (f *fakeODL) send(r request) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.down {
+		return unavailableError{errors.New("connection refused")}
+	}
+	f.sent = append(f.sent, r.method+" "+r.url)
+	return nil
+}
+
+func (f *fakeODL) probe() error {
+	return f.send(request{method: http.MethodGet, url: "/probe"})
+}
+
+func (f *fakeODL) resync() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.resyncs++
+}
+
+func (f *fakeODL) state() ([]string, int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var sent []string
+	for _, s := range f.sent {
+		if s != "GET /probe" {
+			sent = append(sent, s)
+		}
+	}
+	return sent, f.resyncs
+}
+
+func isOpen(b *breaker) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.open
+}
+
+func newTestBreaker(f *fakeODL, threshold int) *breaker {
+	return newBreaker(threshold, 10*time.Millisecond, 0, f.send, f.probe, f.resync)
+}
+
+// eventually waits for cond to hold
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBreakerSends(t *testing.T) {
+	f := &fakeODL{}
+	b := newTestBreaker(f, 3)
+	if err := b.do(request{method: http.MethodPut, url: "/pods/1"}); err != nil {
+		t.Fatalf("do() = %v", err)
+	}
+	sent, resyncs := f.state()
+	if len(sent) != 1 || resyncs != 0 {
+		t.Errorf("sent %v with %d resyncs, expected the PUT alone", sent, resyncs)
+	}
+}
+
+// A request failing below the threshold is retried without further events
+func TestBreakerRetriesBelowThreshold(t *testing.T) {
+	f := &fakeODL{down: true}
+	b := newTestBreaker(f, 3)
+	if err := b.do(request{method: http.MethodPut, url: "/pods/1"}); !isQueued(err) {
+		t.Fatalf("do() = %v, expected the request to be queued", err)
+	}
+	if isOpen(b) {
+		t.Fatal("circuit opened below the threshold")
+	}
+	f.setDown(false)
+	eventually(t, "the retry", func() bool {
+		sent, _ := f.state()
+		return len(sent) == 1
+	})
+}
+
+func TestBreakerOpensQueuesAndRecovers(t *testing.T) {
+	f := &fakeODL{down: true}
+	b := newTestBreaker(f, 2)
+	b.do(request{method: http.MethodPut, url: "/pods/1"})
+	b.do(request{method: http.MethodPut, url: "/pods/2"})
+	// the second request is sent by the drain of the first one
+	eventually(t, "the circuit to open", func() bool { return isOpen(b) })
+
+	// queued while open, superseding the queued PUT of the same url
+	if err := b.do(request{method: http.MethodDelete, url: "/pods/1"}); err == nil || err.Error() != (queuedError{errCircuitOpen}).Error() {
+		t.Fatalf("do() = %v, expected %v", err, queuedError{errCircuitOpen})
+	}
+	b.do(request{method: http.MethodPatch, url: "/pods"})
+
+	f.setDown(false)
+	eventually(t, "the replay and the resync", func() bool {
+		_, resyncs := f.state()
+		return resyncs == 1
+	})
+	sent, _ := f.state()
+	expected := []string{"PUT /pods/2", "DELETE /pods/1", "PATCH /pods"}
+	if len(sent) != len(expected) {
+		t.Fatalf("replayed %v, expected %v", sent, expected)
+	}
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Fatalf("replayed %v, expected %v", sent, expected)
+		}
+	}
+	if err := b.do(request{method: http.MethodPut, url: "/pods/3"}); err != nil {
+		t.Errorf("do() after recovery = %v", err)
+	}
+}
+
+func TestBreakerResyncsAfterOverflow(t *testing.T) {
+	f := &fakeODL{down: true}
+	b := newBreaker(1, 10*time.Millisecond, 1, f.send, f.probe, f.resync)
+	b.do(request{method: http.MethodPut, url: "/pods/1"})
+	b.do(request{method: http.MethodPut, url: "/pods/2"})
+	f.setDown(false)
+	eventually(t, "the resync", func() bool {
+		_, resyncs := f.state()
+		return resyncs == 1
+	})
+	sent, _ := f.state()
+	if len(sent) != 1 || sent[0] != "PUT /pods/1" {
+		t.Errorf("replayed %v, expected the first PUT only", sent)
+	}
+}
+
+// A full queue drops writes, which the resync rewrites, but keeps deletes
+func TestBreakerKeepsDeletesOnOverflow(t *testing.T) {
+	f := &fakeODL{down: true}
+	b := newBreaker(1, 10*time.Millisecond, 2, f.send, f.probe, f.resync)
+	for _, r := range []request{
+		{method: http.MethodPut, url: "/pods/1"},
+		{method: http.MethodPut, url: "/pods/2"},
+		// evicting the queued PUTs, then past the bound
+		{method: http.MethodDelete, url: "/pods/3"},
+		{method: http.MethodDelete, url: "/pods/4"},
+		{method: http.MethodDelete, url: "/pods/5"},
+		{method: http.MethodPut, url: "/pods/6"},
+	} {
+		b.do(r)
+	}
+	f.setDown(false)
+	eventually(t, "the resync", func() bool {
+		_, resyncs := f.state()
+		return resyncs == 1
+	})
+	sent, _ := f.state()
+	expected := []string{"DELETE /pods/3", "DELETE /pods/4", "DELETE /pods/5"}
+	if len(sent) != len(expected) {
+		t.Fatalf("replayed %v, expected %v", sent, expected)
+	}
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Fatalf("replayed %v, expected %v", sent, expected)
+		}
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
 
 	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/journal"
 	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
 )
 
@@ -16,42 +17,131 @@ var Cmd = &cobra.Command{
 	Long:  "Watches Kubernetes and transfers relevant information to OpenDaylight's COE engine",
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("Run ODL watcher")
-		viper.ReadInConfig()
+		commands.RequireClientSet()
 
-		var err error
+		config := loadConfig(cmd)
+		options := config.Options()
 
-		host := viper.GetString("odl.host")
-		if host == "" {
-			host, err = cmd.Flags().GetString("host")
-			if err != nil {
-				log.Panic(err)
-			}
+		var watched backends.Coe
+		options.Resync = func() {
+			backends.Resync(commands.Config.ClientSet, watched)
 		}
-
-		username := viper.GetString("odl.user")
-		if username == "" {
-			username, err = cmd.Flags().GetString("username")
-			if err != nil {
-				log.Panic(err)
+		backend := New(config.Host, config.User, config.Password, options)
+		watched = journal.Use(backend)
+		followsSource := useCredentials(config, backend, true)
+		commands.OnReload(func() {
+			var reloaded Config
+			if err := commands.LoadSection("odl", &reloaded); err != nil {
+				log.Println("Keeping the current ODL configuration:", err)
+				return
 			}
-		}
-
-		password := viper.GetString("odl.password")
-		if password == "" {
-			password, err = cmd.Flags().GetString("password")
-			if err != nil {
-				log.Panic(err)
+			if !followsSource && (reloaded.User != config.User || reloaded.Password != config.Password) {
+				log.Println("Using the new ODL credentials")
+				backend.SetCredentials(Credentials{Username: reloaded.User, Password: reloaded.Password})
+			}
+			config.User, config.Password = reloaded.User, reloaded.Password
+			if reloaded != config {
+				log.Println("ODL connection settings changed, they take effect after a restart")
 			}
+		})
+		if err := backend.AddCluster(); err != nil {
+			log.Printf("unable to create cluster in odl: %s\n", err.Error())
 		}
-		backend := New(host, username, password)
 
-		backends.Watch(commands.Config.ClientSet, backend)
+		backends.Watch(commands.Config.ClientSet, watched)
+		backend.Flush()
 	},
 }
 
+// newReplayBackend creates the backend `coe replay` writes to ODL with.
+func newReplayBackend() (backends.Coe, error) {
+	var config Config
+	if err := commands.LoadSection("odl", &config); err != nil {
+		return nil, err
+	}
+	backend := New(config.Host, config.User, config.Password, config.Options())
+	useCredentials(config, backend, false)
+	if err := backend.AddCluster(); err != nil {
+		log.Printf("unable to create cluster in odl: %s\n", err.Error())
+	}
+	return backend, nil
+}
+
+// loadConfig reads the odl section of the configuration, the flags given to
+// cmd taking precedence over the file and the environment. It exits when the
+// configuration is invalid.
+func loadConfig(cmd *cobra.Command) Config {
+	config, err := readConfig(cmd)
+	if err != nil {
+		log.Fatalln("Invalid configuration:", err)
+	}
+	return config
+}
+
+func readConfig(cmd *cobra.Command) (Config, error) {
+	commands.BindFlags(cmd, flagKeys)
+	var config Config
+	err := commands.LoadSection("odl", &config)
+	return config, err
+}
+
+// useCredentials reads the credentials from the configured file or Secret,
+// if any, and keeps following their rotation when follow is set. It tells
+// whether such a source is used, and exits when they cannot be read.
+func useCredentials(config Config, backend Backend, follow bool) bool {
+	followsSource, err := setCredentials(config, backend, follow)
+	if err != nil {
+		log.Fatalln("Unable to read the ODL credentials:", err)
+	}
+	return followsSource
+}
+
+func setCredentials(config Config, backend Backend, follow bool) (bool, error) {
+	// a nil *Clientset would make a non-nil Interface
+	var clientSet kubernetes.Interface
+	if commands.Config.ClientSet != nil {
+		clientSet = commands.Config.ClientSet
+	}
+	source, err := newCredentialsSource(config.Credentials, clientSet)
+	if err != nil || source == nil {
+		return false, err
+	}
+	if !follow {
+		credentials, err := source()
+		if err != nil {
+			return false, err
+		}
+		backend.SetCredentials(credentials)
+		return true, nil
+	}
+	if err := followCredentials(source, config.Credentials.Refresh, backend); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// addConnectionFlags adds the flags locating ODL to the command and its
+// subcommands.
+func addConnectionFlags(cmd *cobra.Command) {
+	defaults := DefaultConfig()
+	cmd.PersistentFlags().String("host", defaults.Host, "ODL Server to connect to")
+	cmd.PersistentFlags().String("username", defaults.User, "ODL Username")
+	cmd.PersistentFlags().String("password", defaults.Password, "ODL Password")
+	cmd.PersistentFlags().String("credentials-file", defaults.Credentials.File, "Directory holding the ODL username and password, or token, files, e.g. a mounted Secret")
+	cmd.PersistentFlags().String("credentials-secret", defaults.Credentials.Secret, "namespace/name of the Secret holding the ODL username and password, or token")
+}
+
 func init() {
-	Cmd.Flags().String("host", "http://127.0.0.1:8181", "ODL Server to connect to")
-	Cmd.Flags().String("username", "admin", "ODL Username")
-	Cmd.Flags().String("password", "admin", "ODL Password")
+	defaults := DefaultConfig()
+	addConnectionFlags(Cmd)
+	Cmd.Flags().Duration("batch-window", defaults.Batch.Window, "Buffer added objects for this long and write them in bulk (0 disables the bulk mode)")
+	Cmd.Flags().Int("batch-size", defaults.Batch.Size, "Maximum number of objects written to ODL in one bulk request")
+	Cmd.Flags().Duration("request-timeout", defaults.Client.RequestTimeout, "Timeout of a whole request to ODL")
+	Cmd.Flags().Duration("dial-timeout", defaults.Client.DialTimeout, "Timeout of connecting to ODL")
+	Cmd.Flags().Int("max-idle-conns-per-host", defaults.Client.MaxIdleConnsPerHost, "Number of idle connections to ODL kept open")
+	Cmd.Flags().Int("breaker-threshold", defaults.Breaker.Threshold, "Consecutive ODL failures before requests are queued instead of sent (0 disables it)")
+	Cmd.Flags().Duration("breaker-cooldown", defaults.Breaker.Cooldown, "Delay between two checks of an unavailable ODL")
+	Cmd.Flags().Int("queue-size", defaults.Breaker.QueueSize, "Maximum number of requests queued while ODL is unavailable")
 	commands.RootCmd.AddCommand(Cmd)
+	journal.RegisterBackend("odl", newReplayBackend)
 }
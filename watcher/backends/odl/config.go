@@ -0,0 +1,163 @@
+package odl
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Config is the odl section of the configuration file.
+type Config struct {
+	// Host is the url of ODL's RESTCONF server.
+	Host     string `mapstructure:"host"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password" secret:"true"`
+	// Credentials replace User and Password when a source is set.
+	Credentials CredentialsConfig `mapstructure:"credentials"`
+
+	Batch   BatchConfig   `mapstructure:"batch"`
+	Client  ClientConfig  `mapstructure:"client"`
+	Breaker BreakerConfig `mapstructure:"breaker"`
+}
+
+// CredentialsConfig locates ODL credentials kept out of the configuration.
+// Both sources hold a username and a password, or a bearer token, under the
+// keys username, password and token.
+type CredentialsConfig struct {
+	// File is a directory holding one file per key, e.g. where a
+	// Kubernetes Secret is mounted.
+	File string `mapstructure:"file"`
+	// Secret is the namespace/name of a Kubernetes Secret.
+	Secret string `mapstructure:"secret"`
+	// Refresh is how often the source is read again to pick up rotated
+	// credentials.
+	Refresh time.Duration `mapstructure:"refresh"`
+}
+
+// BatchConfig configures the bulk mode, see Options.
+type BatchConfig struct {
+	Window time.Duration `mapstructure:"window"`
+	Size   int           `mapstructure:"size"`
+}
+
+// ClientConfig configures the HTTP client, see Options.
+type ClientConfig struct {
+	RequestTimeout      time.Duration `mapstructure:"request-timeout"`
+	DialTimeout         time.Duration `mapstructure:"dial-timeout"`
+	MaxIdleConnsPerHost int           `mapstructure:"max-idle-conns-per-host"`
+}
+
+// BreakerConfig configures the circuit breaker, see Options.
+type BreakerConfig struct {
+	Threshold int           `mapstructure:"threshold"`
+	Cooldown  time.Duration `mapstructure:"cooldown"`
+	QueueSize int           `mapstructure:"queue-size"`
+}
+
+// flagKeys maps the command line flags to the configuration keys they
+// override.
+var flagKeys = map[string]string{
+	"host":                    "odl.host",
+	"username":                "odl.user",
+	"password":                "odl.password",
+	"credentials-file":        "odl.credentials.file",
+	"credentials-secret":      "odl.credentials.secret",
+	"batch-window":            "odl.batch.window",
+	"batch-size":              "odl.batch.size",
+	"request-timeout":         "odl.client.request-timeout",
+	"dial-timeout":            "odl.client.dial-timeout",
+	"max-idle-conns-per-host": "odl.client.max-idle-conns-per-host",
+	"breaker-threshold":       "odl.breaker.threshold",
+	"breaker-cooldown":        "odl.breaker.cooldown",
+	"queue-size":              "odl.breaker.queue-size",
+}
+
+func DefaultConfig() Config {
+	defaults := DefaultOptions()
+	return Config{
+		Host:     "http://127.0.0.1:8181",
+		User:     "admin",
+		Password: "admin",
+		Credentials: CredentialsConfig{
+			Refresh: time.Minute,
+		},
+		Batch: BatchConfig{
+			Window: defaults.BatchWindow,
+			Size:   defaults.BatchSize,
+		},
+		Client: ClientConfig{
+			RequestTimeout:      defaults.RequestTimeout,
+			DialTimeout:         defaults.DialTimeout,
+			MaxIdleConnsPerHost: defaults.MaxIdleConnsPerHost,
+		},
+		Breaker: BreakerConfig{
+			Threshold: defaults.BreakerThreshold,
+			Cooldown:  defaults.BreakerCooldown,
+			QueueSize: defaults.QueueSize,
+		},
+	}
+}
+
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.Host)
+	if err != nil {
+		return fmt.Errorf("host: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("host: %q is not an http or https url", c.Host)
+	}
+	if c.Credentials.File != "" && c.Credentials.Secret != "" {
+		return fmt.Errorf("credentials: file and secret are mutually exclusive")
+	}
+	if c.Credentials.Secret != "" {
+		if _, _, err := splitSecretName(c.Credentials.Secret); err != nil {
+			return fmt.Errorf("credentials.secret: %v", err)
+		}
+	}
+	if c.Credentials.Refresh <= 0 {
+		return fmt.Errorf("credentials.refresh: must be positive")
+	}
+	if c.Batch.Window < 0 {
+		return fmt.Errorf("batch.window: must not be negative")
+	}
+	if c.Batch.Size <= 0 {
+		return fmt.Errorf("batch.size: must be positive")
+	}
+	if c.Client.RequestTimeout < 0 || c.Client.DialTimeout < 0 {
+		return fmt.Errorf("client: timeouts must not be negative")
+	}
+	if c.Client.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("client.max-idle-conns-per-host: must not be negative")
+	}
+	if c.Breaker.Threshold < 0 {
+		return fmt.Errorf("breaker.threshold: must not be negative")
+	}
+	if c.Breaker.Threshold > 0 && c.Breaker.Cooldown <= 0 {
+		return fmt.Errorf("breaker.cooldown: must be positive when the breaker is enabled")
+	}
+	if c.Breaker.QueueSize < 0 {
+		return fmt.Errorf("breaker.queue-size: must not be negative")
+	}
+	return nil
+}
+
+// Options returns the backend options matching the configuration.
+func (c *Config) Options() Options {
+	options := DefaultOptions()
+	options.BatchWindow = c.Batch.Window
+	options.BatchSize = c.Batch.Size
+	options.RequestTimeout = c.Client.RequestTimeout
+	options.DialTimeout = c.Client.DialTimeout
+	options.MaxIdleConnsPerHost = c.Client.MaxIdleConnsPerHost
+	options.BreakerThreshold = c.Breaker.Threshold
+	options.BreakerCooldown = c.Breaker.Cooldown
+	options.QueueSize = c.Breaker.QueueSize
+	return options
+}
+
+func init() {
+	defaults := DefaultConfig()
+	commands.RegisterSection("odl", &defaults)
+}
@@ -0,0 +1,148 @@
+package odl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Credentials authenticate the requests sent to ODL, with the token as a
+// bearer token when it is set and with basic authentication otherwise.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// credentials holds the current credentials of a backend.
+type credentials struct {
+	lock    sync.RWMutex
+	current Credentials
+}
+
+func (c *credentials) set(current Credentials) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current = current
+}
+
+func (c *credentials) authorize(req *http.Request) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.current.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.current.Token)
+		return
+	}
+	req.SetBasicAuth(c.current.Username, c.current.Password)
+}
+
+// credentialsSource reads the credentials from where they are kept.
+type credentialsSource func() (Credentials, error)
+
+// fileSource reads the credentials from the files username, password and
+// token in dir, the layout of a mounted Kubernetes Secret. Missing files are
+// treated as empty values.
+func fileSource(dir string) credentialsSource {
+	return func() (Credentials, error) {
+		values := make(map[string]string)
+		for _, key := range []string{"username", "password", "token"} {
+			content, err := ioutil.ReadFile(filepath.Join(dir, key))
+			if err != nil && !os.IsNotExist(err) {
+				return Credentials{}, err
+			}
+			values[key] = string(content)
+		}
+		return newCredentials(dir, values)
+	}
+}
+
+// secretSource reads the credentials from the keys username, password and
+// token of a Kubernetes Secret.
+func secretSource(clientSet kubernetes.Interface, namespace, name string) credentialsSource {
+	return func() (Credentials, error) {
+		secret, err := clientSet.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return Credentials{}, err
+		}
+		values := make(map[string]string)
+		for _, key := range []string{"username", "password", "token"} {
+			values[key] = string(secret.Data[key])
+		}
+		return newCredentials("secret "+namespace+"/"+name, values)
+	}
+}
+
+func newCredentials(origin string, values map[string]string) (Credentials, error) {
+	credentials := Credentials{
+		Username: strings.TrimSpace(values["username"]),
+		Password: strings.TrimSpace(values["password"]),
+		Token:    strings.TrimSpace(values["token"]),
+	}
+	if credentials.Token == "" && credentials.Username == "" {
+		return Credentials{}, fmt.Errorf("%s holds neither a token nor a username", origin)
+	}
+	return credentials, nil
+}
+
+func splitSecretName(secret string) (namespace, name string, err error) {
+	parts := strings.Split(secret, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q is not namespace/name", secret)
+	}
+	return parts[0], parts[1], nil
+}
+
+// newCredentialsSource returns the source configured in config, or nil when
+// the credentials are the user and password of the configuration.
+func newCredentialsSource(config CredentialsConfig, clientSet kubernetes.Interface) (credentialsSource, error) {
+	switch {
+	case config.File != "":
+		return fileSource(config.File), nil
+	case config.Secret != "":
+		if clientSet == nil {
+			return nil, fmt.Errorf("reading the credentials from a Secret needs a Kubernetes configuration")
+		}
+		namespace, name, err := splitSecretName(config.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return secretSource(clientSet, namespace, name), nil
+	}
+	return nil, nil
+}
+
+// followCredentials reads the credentials from source every interval and
+// hands them to the backend when they were rotated. It returns once the
+// first read is done, with its error.
+func followCredentials(source credentialsSource, interval time.Duration, backend Backend) error {
+	current, err := source()
+	if err != nil {
+		return err
+	}
+	backend.SetCredentials(current)
+
+	go func() {
+		for range time.Tick(interval) {
+			next, err := source()
+			if err != nil {
+				log.Println("Unable to read the ODL credentials, keeping the current ones:", err)
+				continue
+			}
+			if next != current {
+				log.Println("ODL credentials rotated")
+				backend.SetCredentials(next)
+				current = next
+			}
+		}
+	}()
+	return nil
+}
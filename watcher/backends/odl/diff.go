@@ -0,0 +1,300 @@
+package odl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Exit codes of coe odl diff, telling drift apart from failures.
+const (
+	// exitDrift means that Kubernetes and ODL differ
+	exitDrift = 1
+	// exitFailed means that they could not be compared, e.g. because ODL is
+	// unreachable or the configuration is invalid
+	exitFailed = 2
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare Kubernetes and ODL",
+	Long: `Lists pods, services, endpoints and nodes from Kubernetes and from ODL and
+prints the objects missing from ODL, the extra ones and those which differ.
+Exits with status 0 when the two views are in sync, 1 when they differ and 2
+when they could not be compared, e.g. when ODL is unreachable.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		drifts, err := runDiff(cmd)
+		if err != nil {
+			log.Println(err)
+			os.Exit(exitFailed)
+		}
+		printDrifts(os.Stdout, drifts)
+		if len(drifts) > 0 {
+			os.Exit(exitDrift)
+		}
+	},
+}
+
+// runDiff compares Kubernetes and the ODL of the configuration, returning
+// the errors which prevent it instead of exiting with the drift status.
+func runDiff(cmd *cobra.Command) ([]drift, error) {
+	if commands.Config.ClientSet == nil {
+		return nil, errors.New("no Kubernetes configuration, set kube.config or --kubeconfig, or run inside a cluster")
+	}
+	config, err := readConfig(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	b := New(config.Host, config.User, config.Password, config.Options()).(backend)
+	if _, err := setCredentials(config, b, false); err != nil {
+		return nil, fmt.Errorf("unable to read the ODL credentials: %v", err)
+	}
+	return diff(commands.Config.ClientSet, b)
+}
+
+// drift is an object on which Kubernetes and ODL disagree.
+type drift struct {
+	kind string
+	name string
+	uid  string
+	// reason is "missing from ODL", "extra in ODL" or the leaves which differ
+	reason string
+}
+
+// named is an entry of the ODL model with the name it is reported under and
+// the cluster it belongs to.
+type named struct {
+	name      string
+	clusterID string
+	entry     interface{}
+}
+
+// diff maps every Kubernetes object the way the watcher writes it and
+// compares it to what ODL holds for the backend's cluster.
+func diff(clientSet kubernetes.Interface, b backend) ([]drift, error) {
+	var drifts []drift
+
+	pods, err := clientSet.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pods: %v", err)
+	}
+	odlPods, err := b.Pods()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pods from ODL: %v", err)
+	}
+	desired, actual := make(map[string]named), make(map[string]named)
+	for i := range pods.Items {
+		pod := createOdlPod(&pods.Items[i], b.clusterId)
+		desired[pod.UID] = named{pod.NetworkNS + "/" + pod.Name, pod.ClusterID, pod}
+	}
+	for i := range odlPods {
+		pod := &odlPods[i]
+		actual[pod.UID] = named{pod.NetworkNS + "/" + pod.Name, pod.ClusterID, pod}
+	}
+	drifts = append(drifts, compare("pod", b.clusterId, desired, actual)...)
+
+	services, err := clientSet.CoreV1().Services(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list services: %v", err)
+	}
+	odlServices, err := b.Services()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read services from ODL: %v", err)
+	}
+	desired, actual = make(map[string]named), make(map[string]named)
+	for i := range services.Items {
+		service := createOdlService(&services.Items[i], b.clusterId)
+		desired[service.UID] = named{service.NetworkNS + "/" + service.Name, service.ClusterID, service}
+	}
+	for i := range odlServices {
+		service := &odlServices[i]
+		actual[service.UID] = named{service.NetworkNS + "/" + service.Name, service.ClusterID, service}
+	}
+	drifts = append(drifts, compare("service", b.clusterId, desired, actual)...)
+
+	endpoints, err := clientSet.CoreV1().Endpoints(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list endpoints: %v", err)
+	}
+	odlEndpoints, err := b.Endpoints()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read endpoints from ODL: %v", err)
+	}
+	desired, actual = make(map[string]named), make(map[string]named)
+	for i := range endpoints.Items {
+		endpoint := createOdlEndpoints(&endpoints.Items[i], b.clusterId)
+		desired[endpoint.UID] = named{endpoint.NetworkNS + "/" + endpoint.Name, endpoint.ClusterID, endpoint}
+	}
+	for i := range odlEndpoints {
+		endpoint := &odlEndpoints[i]
+		actual[endpoint.UID] = named{endpoint.NetworkNS + "/" + endpoint.Name, endpoint.ClusterID, endpoint}
+	}
+	drifts = append(drifts, compare("endpoints", b.clusterId, desired, actual)...)
+
+	nodes, err := clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list nodes: %v", err)
+	}
+	odlNodes, err := b.Nodes()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read nodes from ODL: %v", err)
+	}
+	desired, actual = make(map[string]named), make(map[string]named)
+	for i := range nodes.Items {
+		node := createOdlNode(&nodes.Items[i], b.clusterId)
+		desired[node.UID] = named{nodes.Items[i].Name, node.ClusterID, node}
+	}
+	for i := range odlNodes {
+		node := &odlNodes[i]
+		actual[node.UID] = named{node.HostName, node.ClusterID, node}
+	}
+	drifts = append(drifts, compare("node", b.clusterId, desired, actual)...)
+
+	return drifts, nil
+}
+
+// compare reports the entries of the cluster, keyed by uid, which are only
+// desired, only in ODL, or whose leaves differ. Entries are reported under
+// their desired name when there is one.
+func compare(kind, clusterID string, desired, actual map[string]named) []drift {
+	for uid, a := range actual {
+		if a.clusterID != clusterID {
+			delete(actual, uid)
+		}
+	}
+	var drifts []drift
+	for _, uid := range sortedUIDs(desired, actual) {
+		desiredEntry, isDesired := desired[uid]
+		actualEntry, isActual := actual[uid]
+		d := drift{kind: kind, name: desiredEntry.name, uid: uid}
+		switch {
+		case !isActual:
+			d.reason = "missing from ODL"
+		case !isDesired:
+			d.name = actualEntry.name
+			d.reason = "extra in ODL"
+		default:
+			leaves := differingLeaves(desiredEntry.entry, actualEntry.entry)
+			if len(leaves) == 0 {
+				continue
+			}
+			d.reason = "differs: " + strings.Join(leaves, ", ")
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts
+}
+
+// differingLeaves returns the sorted names of the top level leaves which are
+// not equal in the two entries. Nested lists are compared regardless of
+// their order, since ODL does not keep it.
+func differingLeaves(expected, actual interface{}) []string {
+	expectedLeaves, err := toLeaves(expected)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	actualLeaves, err := toLeaves(actual)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var leaves []string
+	for name, value := range expectedLeaves {
+		if other, ok := actualLeaves[name]; !ok || !equalJSON(value, other) {
+			leaves = append(leaves, name)
+		}
+	}
+	for name := range actualLeaves {
+		if _, ok := expectedLeaves[name]; !ok {
+			leaves = append(leaves, name)
+		}
+	}
+	sort.Strings(leaves)
+	return leaves
+}
+
+func equalJSON(a, b json.RawMessage) bool {
+	var aValue, bValue interface{}
+	if json.Unmarshal(a, &aValue) != nil || json.Unmarshal(b, &bValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalize(aValue), normalize(bValue))
+}
+
+// normalize sorts every list of a decoded JSON value.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		keys := make([]string, len(v))
+		for i := range v {
+			v[i] = normalize(v[i])
+			js, _ := json.Marshal(v[i])
+			keys[i] = string(js)
+		}
+		sort.Sort(byKey{v, keys})
+	case map[string]interface{}:
+		for name := range v {
+			v[name] = normalize(v[name])
+		}
+	}
+	return value
+}
+
+// byKey sorts values along with their keys.
+type byKey struct {
+	values []interface{}
+	keys   []string
+}
+
+func (s byKey) Len() int           { return len(s.values) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func sortedUIDs(desired, actual map[string]named) []string {
+	uids := make([]string, 0, len(desired)+len(actual))
+	for uid := range desired {
+		uids = append(uids, uid)
+	}
+	for uid := range actual {
+		if _, ok := desired[uid]; !ok {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+func printDrifts(w io.Writer, drifts []drift) {
+	if len(drifts) == 0 {
+		fmt.Fprintln(w, "Kubernetes and ODL are in sync")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tUID\tDRIFT")
+	for _, d := range drifts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.kind, d.name, d.uid, d.reason)
+	}
+	tw.Flush()
+}
+
+func init() {
+	addConnectionFlags(diffCmd)
+	commands.RootCmd.AddCommand(diffCmd)
+}
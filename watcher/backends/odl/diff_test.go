@@ -0,0 +1,145 @@
+package odl
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl/model"
+)
+
+func namedPod(uid, name, clusterID, phase string, labels ...model.PodLabel) named {
+	pod := &model.Pod{UID: uid, Name: name, ClusterID: clusterID, NetworkNS: "default", Phase: phase, Labels: labels}
+	return named{"default/" + name, clusterID, pod}
+}
+
+func TestCompare(t *testing.T) {
+	desired := map[string]named{
+		"1": namedPod("1", "missing", "cluster", "Running"),
+		"2": namedPod("2", "same", "cluster", "Running"),
+		"3": namedPod("3", "desired-name", "cluster", "Running"),
+	}
+	actual := map[string]named{
+		"2": namedPod("2", "same", "cluster", "Running"),
+		"3": namedPod("3", "odl-name", "cluster", "Pending"),
+		"4": namedPod("4", "extra", "cluster", "Running"),
+		// another cluster's pods are not ours to report
+		"5": namedPod("5", "other", "other-cluster", "Running"),
+	}
+
+	drifts := compare("pod", "cluster", desired, actual)
+	expected := []drift{
+		{kind: "pod", name: "default/missing", uid: "1", reason: "missing from ODL"},
+		{kind: "pod", name: "default/desired-name", uid: "3", reason: "differs: name, phase"},
+		{kind: "pod", name: "default/extra", uid: "4", reason: "extra in ODL"},
+	}
+	if !reflect.DeepEqual(drifts, expected) {
+		t.Errorf("compare() = %+v, expected %+v", drifts, expected)
+	}
+	if drifts := compare("pod", "cluster", desired, map[string]named{}); len(drifts) != 3 {
+		t.Errorf("compare() with nothing in ODL = %+v, expected every pod missing", drifts)
+	}
+}
+
+func TestDifferingLeaves(t *testing.T) {
+	a := model.PodLabel{Key: "app", Value: "web"}
+	b := model.PodLabel{Key: "tier", Value: "front"}
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		leaves   []string
+	}{
+		{
+			name:     "equal",
+			expected: &model.Pod{UID: "1", Phase: "Running"},
+			actual:   &model.Pod{UID: "1", Phase: "Running"},
+		},
+		{
+			name:     "changed leaf",
+			expected: &model.Pod{UID: "1", Phase: "Running"},
+			actual:   &model.Pod{UID: "1", Phase: "Pending"},
+			leaves:   []string{"phase"},
+		},
+		{
+			name:     "leaves only on one side",
+			expected: &model.Pod{UID: "1", NodeName: "node-1"},
+			actual:   &model.Pod{UID: "1", HostIPAddress: "192.168.1.1"},
+			leaves:   []string{"host-ip-address", "node-name"},
+		},
+		{
+			name:     "list in another order",
+			expected: &model.Pod{UID: "1", Labels: []model.PodLabel{a, b}},
+			actual:   &model.Pod{UID: "1", Labels: []model.PodLabel{b, a}},
+		},
+		{
+			name:     "list entry changed",
+			expected: &model.Pod{UID: "1", Labels: []model.PodLabel{a, b}},
+			actual:   &model.Pod{UID: "1", Labels: []model.PodLabel{a, {Key: "tier", Value: "back"}}},
+			leaves:   []string{"labels"},
+		},
+		{
+			name:     "false is a value",
+			expected: &model.Pod{UID: "1", Ready: true},
+			actual:   &model.Pod{UID: "1"},
+			leaves:   []string{"ready"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			leaves := differingLeaves(test.expected, test.actual)
+			if len(leaves) != len(test.leaves) || (len(leaves) > 0 && !reflect.DeepEqual(leaves, test.leaves)) {
+				t.Errorf("differingLeaves() = %v, expected %v", leaves, test.leaves)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		equal bool
+	}{
+		{name: "scalars", a: `"x"`, b: `"x"`, equal: true},
+		{name: "list order", a: `[3, 1, 2]`, b: `[1, 2, 3]`, equal: true},
+		{name: "list of objects", a: `[{"k": "b"}, {"k": "a"}]`, b: `[{"k": "a"}, {"k": "b"}]`, equal: true},
+		{name: "nested lists", a: `{"l": [{"m": [2, 1]}, {"m": [0]}]}`, b: `{"l": [{"m": [0]}, {"m": [1, 2]}]}`, equal: true},
+		{name: "different lengths", a: `[1, 1]`, b: `[1]`},
+		{name: "different entries", a: `[{"k": "a"}]`, b: `[{"k": "b"}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := equalJSON(json.RawMessage(test.a), json.RawMessage(test.b)); equal != test.equal {
+				t.Errorf("equalJSON(%s, %s) = %t, expected %t", test.a, test.b, equal, test.equal)
+			}
+		})
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(`{"l": [{"k": "b"}, {"k": "a"}]}`), &value); err != nil {
+		t.Fatal(err)
+	}
+	js, _ := json.Marshal(normalize(value))
+	if string(js) != `{"l":[{"k":"a"},{"k":"b"}]}` {
+		t.Errorf("normalize() = %s, expected the list sorted", js)
+	}
+}
+
+func TestPrintDrifts(t *testing.T) {
+	var out bytes.Buffer
+	printDrifts(&out, nil)
+	if out.String() != "Kubernetes and ODL are in sync\n" {
+		t.Errorf("printDrifts() without drift = %q", out.String())
+	}
+
+	out.Reset()
+	printDrifts(&out, []drift{{kind: "pod", name: "default/web", uid: "1", reason: "missing from ODL"}})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "KIND") || !strings.Contains(lines[1], "missing from ODL") {
+		t.Errorf("printDrifts() = %q", out.String())
+	}
+}
@@ -0,0 +1,499 @@
+// Package fake provides an in-process RESTCONF server standing in for ODL,
+// so that the odl backend can be exercised without a controller.
+//
+// The server keeps the config datastore in memory, one list entry per url,
+// and understands the requests the backend sends: PUT and DELETE of list
+// entries, PUT of whole containers, GET of entries and containers, and
+// yang-patch PATCH requests on containers. Failures and latency can be
+// injected to exercise the retry paths.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const configPrefix = "/restconf/config/"
+
+// keyLeaves are the leaves used as list keys by the COE model.
+var keyLeaves = []string{"uid", "cluster-id"}
+
+// Request is a request received by the server.
+type Request struct {
+	Method      string
+	Path        string
+	ContentType string
+	Body        []byte
+}
+
+// Server is a fake ODL RESTCONF server.
+type Server struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	username string
+	password string
+	latency  time.Duration
+	failNext []int
+	failPath map[string]int
+	requests []Request
+	// containers maps "module:container" to its lists, each list mapping
+	// the entry keys to the entries.
+	containers map[string]map[string]map[string]map[string]json.RawMessage
+}
+
+// NewServer starts a fake RESTCONF server. It has to be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		failPath:   make(map[string]int),
+		containers: make(map[string]map[string]map[string]map[string]json.RawMessage),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetCredentials makes the server answer 401 to requests not using them.
+func (s *Server) SetCredentials(username, password string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.username = username
+	s.password = password
+}
+
+// SetLatency delays every answer by latency.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latency = latency
+}
+
+// FailNext answers the next n requests with the status code, without
+// touching the datastore.
+func (s *Server) FailNext(n int, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := 0; i < n; i++ {
+		s.failNext = append(s.failNext, status)
+	}
+}
+
+// FailPath answers every request whose path starts with prefix with the
+// status code, until ClearFailures is called.
+func (s *Server) FailPath(prefix string, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failPath[prefix] = status
+}
+
+// ClearFailures removes every injected failure.
+func (s *Server) ClearFailures() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failNext = nil
+	s.failPath = make(map[string]int)
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset empties the datastore and forgets the received requests.
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests = nil
+	s.containers = make(map[string]map[string]map[string]map[string]json.RawMessage)
+}
+
+// Entry returns the stored list entry, as a map of its leaves.
+func (s *Server) Entry(container, list, key string) (map[string]json.RawMessage, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.containers[container][list][key]
+	return entry, ok
+}
+
+// Keys returns the keys of the entries stored in the list, sorted.
+func (s *Server) Keys(container, list string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keys []string
+	for key := range s.containers[container][list] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.lock.Lock()
+	s.requests = append(s.requests, Request{
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		ContentType: r.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	latency := s.latency
+	status := 0
+	if len(s.failNext) > 0 {
+		status = s.failNext[0]
+		s.failNext = s.failNext[1:]
+	}
+	for prefix, failure := range s.failPath {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			status = failure
+		}
+	}
+	username, password, _ := r.BasicAuth()
+	unauthorized := s.username != "" && (username != s.username || password != s.password)
+	s.lock.Unlock()
+
+	time.Sleep(latency)
+	if unauthorized {
+		writeError(w, http.StatusUnauthorized, "access-denied", "bad credentials")
+		return
+	}
+	if status != 0 {
+		writeError(w, status, "operation-failed", "injected failure")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, configPrefix) {
+		writeError(w, http.StatusNotFound, "invalid-value", "only the config datastore is supported")
+		return
+	}
+
+	// path is module:container[/list[/key]]
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, configPrefix), "/"), "/")
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case r.Method == http.MethodGet:
+		s.get(w, segments)
+	case r.Method == http.MethodPut && len(segments) == 1:
+		s.putContainer(w, segments[0], body)
+	case r.Method == http.MethodPut && len(segments) == 3:
+		s.putEntry(w, segments, body)
+	case r.Method == http.MethodDelete:
+		s.delete(w, segments)
+	case r.Method == http.MethodPatch && len(segments) == 1:
+		s.patch(w, segments[0], body)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "operation-not-supported", r.Method+" "+r.URL.Path)
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, segments []string) {
+	lists, ok := s.containers[segments[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "data-missing", "no such container")
+		return
+	}
+	module, _ := splitQualified(segments[0])
+	switch len(segments) {
+	case 1:
+		content := make(map[string][]map[string]json.RawMessage)
+		for list, entries := range lists {
+			for _, key := range sortedKeys(entries) {
+				content[list] = append(content[list], entries[key])
+			}
+		}
+		writeJSON(w, map[string]interface{}{segments[0]: content})
+	case 2:
+		entries, ok := lists[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "data-missing", "no such list")
+			return
+		}
+		var values []map[string]json.RawMessage
+		for _, key := range sortedKeys(entries) {
+			values = append(values, entries[key])
+		}
+		writeJSON(w, map[string]interface{}{module + ":" + segments[1]: values})
+	default:
+		entry, ok := lists[segments[1]][segments[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "data-missing", "no such entry")
+			return
+		}
+		writeJSON(w, map[string]interface{}{module + ":" + segments[1]: []interface{}{entry}})
+	}
+}
+
+func (s *Server) putContainer(w http.ResponseWriter, container string, body []byte) {
+	var document map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed-message", err.Error())
+		return
+	}
+	content, ok := lookupContainer(document, container)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "malformed-message", "body does not match "+container)
+		return
+	}
+	lists := make(map[string]map[string]map[string]json.RawMessage)
+	for list, value := range content {
+		// only the lists of the container are kept, not its leaves
+		var entries []map[string]json.RawMessage
+		if json.Unmarshal(value, &entries) != nil {
+			continue
+		}
+		lists[list] = make(map[string]map[string]json.RawMessage)
+		for i, entry := range entries {
+			lists[list][entryKey(entry, i)] = entry
+		}
+	}
+	s.containers[container] = lists
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) putEntry(w http.ResponseWriter, segments []string, body []byte) {
+	var document map[string][]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed-message", err.Error())
+		return
+	}
+	entries, ok := lookupList(document, segments[1])
+	if !ok || len(entries) != 1 {
+		writeError(w, http.StatusBadRequest, "malformed-message", "body must hold exactly one "+segments[1]+" entry")
+		return
+	}
+	if key := entryKey(entries[0], -1); key != segments[2] {
+		writeError(w, http.StatusBadRequest, "invalid-value", "key in body does not match the url")
+		return
+	}
+	s.list(segments[0], segments[1])[segments[2]] = entries[0]
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) delete(w http.ResponseWriter, segments []string) {
+	lists, ok := s.containers[segments[0]]
+	switch {
+	case !ok:
+	case len(segments) == 1:
+		delete(s.containers, segments[0])
+		w.WriteHeader(http.StatusOK)
+		return
+	case len(segments) == 2:
+		if _, found := lists[segments[1]]; found {
+			delete(lists, segments[1])
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	default:
+		if _, found := lists[segments[1]][segments[2]]; found {
+			delete(lists[segments[1]], segments[2])
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "data-missing", "nothing to delete")
+}
+
+type yangPatch struct {
+	Patch struct {
+		PatchID string `json:"patch-id"`
+		Edits   []struct {
+			EditID    string          `json:"edit-id"`
+			Operation string          `json:"operation"`
+			Target    string          `json:"target"`
+			Value     json.RawMessage `json:"value"`
+		} `json:"edit"`
+	} `json:"ietf-yang-patch:yang-patch"`
+}
+
+// patch applies a yang-patch whose targets are /list=key or
+// /list=key/leaf, all or nothing.
+func (s *Server) patch(w http.ResponseWriter, container string, body []byte) {
+	var patch yangPatch
+	if err := json.Unmarshal(body, &patch); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed-message", err.Error())
+		return
+	}
+
+	// work on a copy so that a failing edit leaves the datastore untouched
+	lists := make(map[string]map[string]map[string]json.RawMessage)
+	for list, entries := range s.containers[container] {
+		lists[list] = make(map[string]map[string]json.RawMessage)
+		for key, entry := range entries {
+			copied := make(map[string]json.RawMessage)
+			for leaf, value := range entry {
+				copied[leaf] = value
+			}
+			lists[list][key] = copied
+		}
+	}
+
+	for _, edit := range patch.Patch.Edits {
+		parts := strings.Split(strings.TrimPrefix(edit.Target, "/"), "/")
+		listKey := strings.SplitN(parts[0], "=", 2)
+		if len(listKey) != 2 || len(parts) > 2 {
+			writeError(w, http.StatusBadRequest, "invalid-value", "unsupported target "+edit.Target)
+			return
+		}
+		list, key := listKey[0], listKey[1]
+		if lists[list] == nil {
+			lists[list] = make(map[string]map[string]json.RawMessage)
+		}
+		entry, exists := lists[list][key]
+
+		switch edit.Operation {
+		case "create", "replace", "merge":
+			if len(parts) == 2 {
+				writeError(w, http.StatusBadRequest, "invalid-value", edit.Operation+" of a leaf is not supported")
+				return
+			}
+			var document map[string][]map[string]json.RawMessage
+			if err := json.Unmarshal(edit.Value, &document); err != nil {
+				writeError(w, http.StatusBadRequest, "malformed-message", err.Error())
+				return
+			}
+			values, ok := lookupList(document, list)
+			if !ok || len(values) != 1 || entryKey(values[0], -1) != key {
+				writeError(w, http.StatusBadRequest, "invalid-value", "value does not match "+edit.Target)
+				return
+			}
+			if edit.Operation == "create" && exists {
+				writeError(w, http.StatusConflict, "data-exists", edit.Target)
+				return
+			}
+			if edit.Operation == "merge" && exists {
+				for leaf, value := range values[0] {
+					entry[leaf] = value
+				}
+			} else {
+				lists[list][key] = values[0]
+			}
+		case "delete", "remove":
+			if !exists {
+				if edit.Operation == "delete" {
+					writeError(w, http.StatusNotFound, "data-missing", edit.Target)
+					return
+				}
+				continue
+			}
+			if len(parts) == 2 {
+				if _, found := entry[parts[1]]; !found && edit.Operation == "delete" {
+					writeError(w, http.StatusNotFound, "data-missing", edit.Target)
+					return
+				}
+				delete(entry, parts[1])
+				// leaves may be namespace qualified in the target
+				if _, name := splitQualified(parts[1]); name != parts[1] {
+					delete(entry, name)
+				}
+			} else {
+				delete(lists[list], key)
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "operation-not-supported", edit.Operation)
+			return
+		}
+	}
+
+	s.containers[container] = lists
+	writeJSON(w, map[string]interface{}{
+		"ietf-yang-patch:yang-patch-status": map[string]interface{}{
+			"patch-id": patch.Patch.PatchID,
+			"ok":       []interface{}{nil},
+		},
+	})
+}
+
+func (s *Server) list(container, list string) map[string]map[string]json.RawMessage {
+	if s.containers[container] == nil {
+		s.containers[container] = make(map[string]map[string]map[string]json.RawMessage)
+	}
+	if s.containers[container][list] == nil {
+		s.containers[container][list] = make(map[string]map[string]json.RawMessage)
+	}
+	return s.containers[container][list]
+}
+
+// entryKey returns the key of a list entry, or its index when it has no
+// known key leaf.
+func entryKey(entry map[string]json.RawMessage, index int) string {
+	for _, leaf := range keyLeaves {
+		for name, value := range entry {
+			if _, unqualified := splitQualified(name); unqualified == leaf {
+				var key string
+				if json.Unmarshal(value, &key) == nil {
+					return key
+				}
+			}
+		}
+	}
+	return fmt.Sprint(index)
+}
+
+// lookupContainer finds the container named name in a document, with or
+// without its module prefix.
+func lookupContainer(document map[string]map[string]json.RawMessage, name string) (map[string]json.RawMessage, bool) {
+	_, unqualified := splitQualified(name)
+	for member, content := range document {
+		if _, other := splitQualified(member); other == unqualified {
+			return content, true
+		}
+	}
+	return nil, false
+}
+
+// lookupList finds the list named name in a document, with or without its
+// module prefix.
+func lookupList(document map[string][]map[string]json.RawMessage, name string) ([]map[string]json.RawMessage, bool) {
+	_, unqualified := splitQualified(name)
+	for member, entries := range document {
+		if _, other := splitQualified(member); other == unqualified {
+			return entries, true
+		}
+	}
+	return nil, false
+}
+
+func splitQualified(name string) (module, unqualified string) {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+func sortedKeys(entries map[string]map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, tag, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": map[string]interface{}{
+			"error": []interface{}{
+				map[string]string{
+					"error-type":    "application",
+					"error-tag":     tag,
+					"error-message": message,
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,196 @@
+package odl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl/model"
+)
+
+// kinds are the objects which can be read back from ODL.
+var kinds = []string{"pods", "services", "endpoints", "nodes", "clusters"}
+
+var getCmd = &cobra.Command{
+	Use:       "get pods|services|endpoints|nodes|clusters [name]",
+	Short:     "Show what ODL holds",
+	Long:      "Reads the COE containers from ODL's config datastore and prints them, optionally only the entries with the given name or uid",
+	ValidArgs: kinds,
+	Args:      cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			log.Panic(err)
+		}
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+
+		config := loadConfig(cmd)
+		backend := New(config.Host, config.User, config.Password, config.Options())
+		useCredentials(config, backend, false)
+
+		entries, table, err := getEntries(backend, args[0], name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := printEntries(os.Stdout, output, entries, table); err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// getEntries reads one kind of object from ODL, keeping only those matching
+// name when it is not empty. The entries are returned as is, for JSON and
+// YAML output, and as table rows whose first row is the header.
+func getEntries(backend Backend, kind, name string) (interface{}, [][]string, error) {
+	switch kind {
+	case "pods":
+		pods, err := backend.Pods()
+		if err != nil {
+			return nil, nil, err
+		}
+		var selected []model.Pod
+		table := [][]string{{"NAME", "NAMESPACE", "NODE", "PHASE", "READY", "IP", "UID"}}
+		for _, pod := range pods {
+			if !matches(name, pod.Name, pod.UID) {
+				continue
+			}
+			var addresses []string
+			for _, intf := range pod.Interface {
+				addresses = append(addresses, intf.IPAddress)
+			}
+			selected = append(selected, pod)
+			table = append(table, []string{pod.Name, pod.NetworkNS, pod.NodeName, pod.Phase,
+				fmt.Sprint(pod.Ready), strings.Join(addresses, ","), pod.UID})
+		}
+		return selected, table, nil
+	case "services":
+		services, err := backend.Services()
+		if err != nil {
+			return nil, nil, err
+		}
+		var selected []model.Service
+		table := [][]string{{"NAME", "NAMESPACE", "CLUSTER-IP", "PORTS", "UID"}}
+		for _, service := range services {
+			if !matches(name, service.Name, service.UID) {
+				continue
+			}
+			var ports []string
+			for _, port := range service.ServicePorts {
+				ports = append(ports, fmt.Sprintf("%d:%s", port.Port, port.TargetPort))
+			}
+			selected = append(selected, service)
+			table = append(table, []string{service.Name, service.NetworkNS, service.ClusterIPAddress,
+				strings.Join(ports, ","), service.UID})
+		}
+		return selected, table, nil
+	case "endpoints":
+		endpoints, err := backend.Endpoints()
+		if err != nil {
+			return nil, nil, err
+		}
+		var selected []model.Endpoint
+		table := [][]string{{"NAME", "NAMESPACE", "ADDRESSES", "PORTS", "UID"}}
+		for _, endpoint := range endpoints {
+			if !matches(name, endpoint.Name, endpoint.UID) {
+				continue
+			}
+			var addresses, ports []string
+			for _, address := range endpoint.EndpointAddresses {
+				addresses = append(addresses, address.IPAddress)
+			}
+			for _, port := range endpoint.EndpointPorts {
+				ports = append(ports, fmt.Sprint(port.Port))
+			}
+			selected = append(selected, endpoint)
+			table = append(table, []string{endpoint.Name, endpoint.NetworkNS, strings.Join(addresses, ","),
+				strings.Join(ports, ","), endpoint.UID})
+		}
+		return selected, table, nil
+	case "nodes":
+		nodes, err := backend.Nodes()
+		if err != nil {
+			return nil, nil, err
+		}
+		var selected []model.K8sNode
+		table := [][]string{{"NAME", "INTERNAL-IP", "EXTERNAL-IP", "POD-CIDR", "READY", "UID"}}
+		for _, node := range nodes {
+			if !matches(name, node.HostName, node.UID) {
+				continue
+			}
+			selected = append(selected, node)
+			table = append(table, []string{node.HostName, node.InternalIPAddress, node.ExternalIPAddress,
+				node.PodCIDR, fmt.Sprint(node.Ready), node.UID})
+		}
+		return selected, table, nil
+	case "clusters":
+		clusters, err := backend.Clusters()
+		if err != nil {
+			return nil, nil, err
+		}
+		var selected []model.K8sCluster
+		table := [][]string{{"CLUSTER-ID"}}
+		for _, cluster := range clusters {
+			if !matches(name, cluster.ClusterID) {
+				continue
+			}
+			selected = append(selected, cluster)
+			table = append(table, []string{cluster.ClusterID})
+		}
+		return selected, table, nil
+	}
+	return nil, nil, fmt.Errorf("unknown kind %q, expected one of %s", kind, strings.Join(kinds, ", "))
+}
+
+// matches tells whether name is empty or one of the values.
+func matches(name string, values ...string) bool {
+	if name == "" {
+		return true
+	}
+	for _, value := range values {
+		if value == name {
+			return true
+		}
+	}
+	return false
+}
+
+func printEntries(w io.Writer, output string, entries interface{}, table [][]string) error {
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, row := range table {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		js, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(js))
+		return err
+	case "yaml":
+		js, err := yaml.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(js)
+		return err
+	}
+	return fmt.Errorf("unknown output format %q, expected table, json or yaml", output)
+}
+
+func init() {
+	getCmd.Flags().StringP("output", "o", "table", "Output format: table, json or yaml")
+	Cmd.AddCommand(getCmd)
+}
@@ -1,89 +1,26 @@
 package odl
 
 import (
-	"net"
-
-	"k8s.io/apimachinery/pkg/types"
+	"encoding/json"
 )
 
-type Namespace struct {
-	Coe Coe `json:"coe"`
-}
-
-type Coe struct {
-	Clusters *ClusterContainer `json:"k8s-clusters-info,omitempty"`
-	Pods []Pod `json:"pods,omitempty"`
-}
-
-type ClusterContainer struct {
-	Clusters []Cluster `json:"k8s-clusters,omitempty"`
-}
-
-type Cluster struct {
-	ClusterID string `json:"cluster-id"`
-}
-
-type Pod struct {
-	UID            types.UID   `json:"uid"`
-	ClusterID      string      `json:"cluster-id"`
-	Name           string      `json:"name"`
-	HostIPAddress  string      `json:"host-ip-address,omitempty"`
-	NetworkNS      string      `json:"network-NS"`
-	PortMacAddress string      `json:"port-mac-address"`
-	Interfaces     []Interface `json:"interface"`
-}
-
-type Interface struct {
-	UID         types.UID `json:"uid"`
-	IPAddress   net.IP    `json:"ip-address,omitempty"`
-	NetworkID   string    `json:"network-id"`
-	NetworkType string    `json:"network-type"`
-}
-
-type Node struct {
-	UID               types.UID `json:"k8s-node:uid"`
-	ClusterID         string    `json:"k8s-node:cluster-id"`
-	PodCIDR           string    `json:"k8s-node:pod-cidr,omitempty"`
-	HostName          string    `json:"k8s-node:host-name,omitempty"`
-	InternalIPAddress net.IP    `json:"k8s-node:internal-ip-address,omitempty"`
-	ExternalIPAddress net.IP    `json:"k8s-node:external-ip-address,omitempty"`
-}
-
-type Service struct {
-	UID                   types.UID      `json:"service:uid"`
-	ClusterID             string         `json:"service:cluster-id"`
-	Name                  string         `json:"service:name"`
-	ClusterIPAddress      net.IP         `json:"service:cluster-ip-address"`
-	NetworkNS             string         `json:"service:network-NS"`
-	ExternalIPAddress     []net.IP       `json:"service:external-ip-address,omitempty"`
-	LoadBalancerIPAddress net.IP         `json:"service:load-balancer-IP,omitempty"`
-	IngressIPAddress      []net.IP       `json:"service:ingress-ip-address,omitempty"`
-	ServicePorts          []ServicePorts `json:"service:service-ports"`
-}
-
-type ServicePorts struct {
-	Name       string `json:"service:name"`
-	Port       int32  `json:"service:port"`
-	TargetPort string `json:"service:target-port"`
-	NodePort   int32  `json:"service:node-port"`
-}
+// The COE model itself is generated from the YANG modules in the model
+// package, only the RESTCONF protocol documents are defined here.
 
-type EndPoints struct {
-	UID               types.UID            `json:"service:uid"`
-	ClusterID         string               `json:"service:cluster-id"`
-	Name              string               `json:"service:name"`
-	NetworkNS         string               `json:"service:network-NS"`
-	EndPointAddresses []EndPointsAddresses `json:"service:endpoint-addresses,omitempty"`
-	EndPointPorts     []EndPointsPorts     `json:"service:endpoint-ports,omitempty"`
+// YangPatch is a RFC 8072 yang-patch document, used to write many list
+// entries of a container in a single request.
+type YangPatch struct {
+	Patch YangPatchBody `json:"ietf-yang-patch:yang-patch"`
 }
 
-type EndPointsAddresses struct {
-	IPAddress net.IP  `json:"service:ip-address"`
-	HostName  string  `json:"service:host-name"`
-	NodeName  *string `json:"service:node-name"`
+type YangPatchBody struct {
+	PatchID string          `json:"patch-id"`
+	Edits   []YangPatchEdit `json:"edit"`
 }
 
-type EndPointsPorts struct {
-	Name string `json:"service:name"`
-	Port int32  `json:"service:port"`
+type YangPatchEdit struct {
+	EditID    string          `json:"edit-id"`
+	Operation string          `json:"operation"`
+	Target    string          `json:"target"`
+	Value     json.RawMessage `json:"value,omitempty"`
 }
@@ -0,0 +1,8 @@
+// Package model holds the Go types of the COE northbound model, generated
+// from its YANG modules. Every type serializes to the RESTCONF JSON encoding
+// of the corresponding YANG node and has a Validate method checking the
+// constraints of the schema, so that invalid payloads are rejected before
+// they reach ODL.
+package model
+
+//go:generate go run ./gen -yang ../../../../northbound/api/src/main/yang -out . pod k8s-node service k8s-cluster
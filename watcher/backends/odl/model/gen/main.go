@@ -0,0 +1,532 @@
+// gen generates the Go types of the ODL COE northbound model from its YANG
+// modules, along with their Validate methods.
+//
+// It only understands the subset of YANG used by the northbound modules:
+// containers, lists, leaves, leaf-lists, local groupings and the string,
+// boolean, integer, enumeration, inet:ip-address and yang:uuid types.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type statement struct {
+	keyword  string
+	arg      string
+	children []*statement
+}
+
+func (s *statement) child(keyword string) *statement {
+	for _, child := range s.children {
+		if child.keyword == keyword {
+			return child
+		}
+	}
+	return nil
+}
+
+// tokenize splits a YANG module into words, quoted strings and the { } ;
+// punctuation, dropping the comments and joining strings concatenated
+// with +.
+func tokenize(src string) ([]string, error) {
+	var tokens []string
+	concat := false
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '+':
+			concat = true
+			i++
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if c == '"' && src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[j])
+					}
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			if concat && len(tokens) > 0 {
+				tokens[len(tokens)-1] += sb.String()
+			} else {
+				tokens = append(tokens, sb.String())
+			}
+			concat = false
+			i = j + 1
+		default:
+			j := i
+			for j < len(src) && !unicode.IsSpace(rune(src[j])) && !strings.ContainsRune("{};", rune(src[j])) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func parse(tokens []string) ([]*statement, []string, error) {
+	var statements []*statement
+	for len(tokens) > 0 {
+		if tokens[0] == "}" {
+			return statements, tokens[1:], nil
+		}
+		s := &statement{keyword: tokens[0]}
+		tokens = tokens[1:]
+		if len(tokens) > 0 && tokens[0] != ";" && tokens[0] != "{" {
+			s.arg = tokens[0]
+			tokens = tokens[1:]
+		}
+		if len(tokens) == 0 {
+			return nil, nil, fmt.Errorf("unexpected end of module after %s", s.keyword)
+		}
+		if tokens[0] == "{" {
+			var err error
+			s.children, tokens, err = parse(tokens[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			tokens = tokens[1:]
+		}
+		statements = append(statements, s)
+	}
+	return statements, tokens, nil
+}
+
+type module struct {
+	name      string
+	groupings map[string]*statement
+}
+
+// node is a data node of the schema tree, with the groupings expanded.
+type node struct {
+	kind        string
+	name        string
+	description string
+	yangType    string
+	enums       []string
+	mandatory   bool
+	keys        []string
+	children    []*node
+	goName      string
+	goType      string
+}
+
+func (m *module) expand(statements []*statement) ([]*node, error) {
+	var nodes []*node
+	for _, s := range statements {
+		switch s.keyword {
+		case "container", "list", "leaf", "leaf-list":
+			n := &node{kind: s.keyword, name: s.arg}
+			if d := s.child("description"); d != nil {
+				n.description = strings.Join(strings.Fields(d.arg), " ")
+			}
+			if mandatory := s.child("mandatory"); mandatory != nil {
+				n.mandatory = mandatory.arg == "true"
+			}
+			if key := s.child("key"); key != nil {
+				n.keys = strings.Fields(key.arg)
+			}
+			if t := s.child("type"); t != nil {
+				n.yangType = t.arg
+				for _, enum := range t.children {
+					if enum.keyword == "enum" {
+						n.enums = append(n.enums, enum.arg)
+					}
+				}
+			}
+			children, err := m.expand(s.children)
+			if err != nil {
+				return nil, err
+			}
+			n.children = children
+			nodes = append(nodes, n)
+		case "uses":
+			name := s.arg
+			if i := strings.IndexByte(name, ':'); i >= 0 {
+				name = name[i+1:]
+			}
+			grouping, ok := m.groupings[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown grouping %s", m.name, s.arg)
+			}
+			children, err := m.expand(grouping.children)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, children...)
+		}
+	}
+	return nodes, nil
+}
+
+var initialisms = map[string]string{
+	"cidr":  "CIDR",
+	"cidrs": "CIDRs",
+	"dns":   "DNS",
+	"id":    "ID",
+	"ip":    "IP",
+	"mac":   "MAC",
+	"ns":    "NS",
+	"uid":   "UID",
+}
+
+func camelCase(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' }) {
+		if initialism, ok := initialisms[strings.ToLower(part)]; ok {
+			sb.WriteString(initialism)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "sses"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "ss"):
+		return name
+	case strings.HasSuffix(name, "s"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// lastWord returns the last CamelCase word of name.
+func lastWord(name string) string {
+	for i := len(name) - 1; i > 0; i-- {
+		if unicode.IsUpper(rune(name[i])) {
+			return name[i:]
+		}
+	}
+	return name
+}
+
+// nestedTypeName prefixes a nested type with its parent type, without
+// repeating the word they share, e.g. K8sNode and NodeAddress give
+// K8sNodeAddress.
+func nestedTypeName(parent, child string) string {
+	word := lastWord(parent)
+	if strings.HasPrefix(child, word) {
+		return parent + strings.TrimPrefix(child, word)
+	}
+	return parent + child
+}
+
+// name assigns the Go names of the nodes under parent, whose type is
+// parentType ("" at the top level).
+func name(nodes []*node, parentType string) {
+	for _, n := range nodes {
+		n.goName = camelCase(n.name)
+		switch n.kind {
+		case "container", "list":
+			typeName := camelCase(n.name)
+			if n.kind == "list" {
+				typeName = camelCase(singular(n.name))
+			}
+			if n.kind == "list" && parentType != "" && !isTopContainer(parentType) {
+				typeName = nestedTypeName(parentType, typeName)
+			}
+			n.goType = typeName
+			name(n.children, typeName)
+		case "leaf":
+			n.goType = goLeafType(n.yangType)
+		case "leaf-list":
+			n.goType = "[]" + goLeafType(n.yangType)
+		}
+	}
+}
+
+// topContainers holds the type names of the top level containers, whose
+// lists are named on their own rather than after the container.
+var topContainers = map[string]bool{}
+
+func isTopContainer(typeName string) bool {
+	return topContainers[typeName]
+}
+
+func goLeafType(yangType string) string {
+	switch yangType {
+	case "boolean":
+		return "bool"
+	case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+		return yangType
+	}
+	return "string"
+}
+
+type generator struct {
+	buf      bytes.Buffer
+	module   string
+	done     map[string]bool
+	needsFmt bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) comment(indent, text string) {
+	if text == "" {
+		return
+	}
+	line := indent + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+len(word)+1 > 80 && line != indent+"//" {
+			g.printf("%s\n", line)
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	g.printf("%s\n", line)
+}
+
+func (g *generator) structType(n *node) {
+	if g.done[n.goType] {
+		return
+	}
+	g.done[n.goType] = true
+
+	g.comment("", fmt.Sprintf("%s is the %s %s of the %s module. %s", n.goType, n.name, n.kind, g.module, n.description))
+	g.printf("type %s struct {\n", n.goType)
+	for _, child := range n.children {
+		g.comment("\t", child.description)
+		// A false boolean is a value, not an absent leaf
+		tag := child.name + ",omitempty"
+		if contains(n.keys, child.name) || (child.kind == "leaf" && child.goType == "bool") {
+			tag = child.name
+		}
+		switch child.kind {
+		case "container":
+			g.printf("\t%s *%s `json:%q`\n", child.goName, child.goType, tag)
+		case "list":
+			g.printf("\t%s []%s `json:%q`\n", child.goName, child.goType, tag)
+		default:
+			g.printf("\t%s %s `json:%q`\n", child.goName, child.goType, tag)
+		}
+	}
+	g.printf("}\n\n")
+
+	g.validate(n)
+
+	for _, child := range n.children {
+		if child.kind == "container" || child.kind == "list" {
+			g.structType(child)
+		}
+	}
+}
+
+func (g *generator) validate(n *node) {
+	if len(n.keys) > 0 {
+		g.printf("// ListKey returns the key of the %s list entry.\n", n.name)
+		g.printf("func (x *%s) ListKey() string {\n", n.goType)
+		var parts []string
+		for _, key := range n.keys {
+			for _, child := range n.children {
+				if child.name != key {
+					continue
+				}
+				if child.goType == "string" {
+					parts = append(parts, "x."+child.goName)
+				} else {
+					parts = append(parts, fmt.Sprintf("fmt.Sprint(x.%s)", child.goName))
+					g.needsFmt = true
+				}
+			}
+		}
+		g.printf("\treturn %s\n}\n\n", strings.Join(parts, ` + " " + `))
+	}
+
+	g.printf("// Validate checks the %s %s against the %s module.\n", n.name, n.kind, g.module)
+	g.printf("func (x *%s) Validate() error {\n", n.goType)
+	for _, child := range n.children {
+		switch child.kind {
+		case "leaf":
+			if contains(n.keys, child.name) || child.mandatory {
+				zero := `""`
+				switch child.goType {
+				case "bool":
+					zero = ""
+				case "string":
+				default:
+					zero = "0"
+				}
+				if zero != "" {
+					g.printf("\tif x.%s == %s {\n\t\treturn missing(%q)\n\t}\n", child.goName, zero, child.name)
+				}
+			}
+			g.leafCheck(child, "x."+child.goName)
+		case "leaf-list":
+			if child.yangType == "inet:ip-address" || child.yangType == "yang:uuid" || len(child.enums) > 0 {
+				g.printf("\tfor _, value := range x.%s {\n", child.goName)
+				g.leafCheck(child, "value")
+				g.printf("\t}\n")
+			}
+		case "container":
+			if child.mandatory {
+				g.printf("\tif x.%s == nil {\n\t\treturn missing(%q)\n\t}\n", child.goName, child.name)
+			}
+			g.printf("\tif x.%s != nil {\n", child.goName)
+			g.printf("\t\tif err := x.%s.Validate(); err != nil {\n\t\t\treturn nested(%q, err)\n\t\t}\n\t}\n", child.goName, child.name)
+		case "list":
+			if len(child.keys) > 0 {
+				g.printf("\tseen%s := make(map[string]bool, len(x.%s))\n", child.goName, child.goName)
+			}
+			g.printf("\tfor i := range x.%s {\n", child.goName)
+			g.printf("\t\tif err := x.%s[i].Validate(); err != nil {\n\t\t\treturn nestedEntry(%q, i, err)\n\t\t}\n", child.goName, child.name)
+			if len(child.keys) > 0 {
+				g.printf("\t\tkey := x.%s[i].ListKey()\n", child.goName)
+				g.printf("\t\tif seen%s[key] {\n\t\t\treturn duplicate(%q, key)\n\t\t}\n", child.goName, child.name)
+				g.printf("\t\tseen%s[key] = true\n", child.goName)
+			}
+			g.printf("\t}\n")
+		}
+	}
+	g.printf("\treturn nil\n}\n\n")
+}
+
+func (g *generator) leafCheck(n *node, value string) {
+	switch {
+	case n.yangType == "inet:ip-address":
+		g.printf("\tif err := validateIPAddress(%q, %s); err != nil {\n\t\treturn err\n\t}\n", n.name, value)
+	case n.yangType == "yang:uuid":
+		g.printf("\tif err := validateUUID(%q, %s); err != nil {\n\t\treturn err\n\t}\n", n.name, value)
+	case len(n.enums) > 0:
+		quoted := make([]string, len(n.enums))
+		for i, enum := range n.enums {
+			quoted[i] = fmt.Sprintf("%q", enum)
+		}
+		g.printf("\tif err := validateEnum(%q, %s, %s); err != nil {\n\t\treturn err\n\t}\n", n.name, value, strings.Join(quoted, ", "))
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func generate(dir, moduleName string) ([]byte, error) {
+	src, err := ioutil.ReadFile(filepath.Join(dir, moduleName+".yang"))
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokenize(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", moduleName, err)
+	}
+	statements, _, err := parse(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", moduleName, err)
+	}
+	if len(statements) != 1 || statements[0].keyword != "module" {
+		return nil, fmt.Errorf("%s: not a YANG module", moduleName)
+	}
+	root := statements[0]
+
+	m := &module{name: root.arg, groupings: make(map[string]*statement)}
+	for _, s := range root.children {
+		if s.keyword == "grouping" {
+			m.groupings[s.arg] = s
+		}
+	}
+	nodes, err := m.expand(root.children)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		if n.kind == "container" {
+			topContainers[camelCase(n.name)] = true
+		}
+	}
+	name(nodes, "")
+
+	g := &generator{module: m.name, done: make(map[string]bool)}
+	for _, n := range nodes {
+		if n.kind != "container" {
+			continue
+		}
+		g.printf("// %sDocument is the body of a request on the %s container.\n", n.goType, n.name)
+		g.printf("type %sDocument struct {\n\t%s *%s `json:\"%s:%s,omitempty\"`\n}\n\n", n.goType, n.goName, n.goType, m.name, n.name)
+		for _, child := range n.children {
+			if child.kind != "list" {
+				continue
+			}
+			g.printf("// %sList is the body of a request on a single %s list entry.\n", child.goType, child.name)
+			g.printf("type %sList struct {\n\t%s []%s `json:\"%s:%s\"`\n}\n\n", child.goType, child.goName, child.goType, m.name, child.name)
+		}
+		g.structType(n)
+	}
+
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "// Code generated by gen from %s.yang. DO NOT EDIT.\n\n", m.name)
+	fmt.Fprintf(&header, "package model\n\n")
+	if g.needsFmt {
+		fmt.Fprintf(&header, "import \"fmt\"\n\n")
+	}
+	return format.Source(append(header.Bytes(), g.buf.Bytes()...))
+}
+
+func main() {
+	dir := flag.String("yang", "", "directory holding the YANG modules")
+	out := flag.String("out", ".", "directory receiving the generated files")
+	flag.Parse()
+
+	modules := flag.Args()
+	sort.Strings(modules)
+	for _, moduleName := range modules {
+		src, err := generate(*dir, moduleName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		file := filepath.Join(*out, strings.Replace(moduleName, "-", "_", -1)+".go")
+		if err := ioutil.WriteFile(file, src, 0644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr, "generated", file)
+	}
+}
@@ -0,0 +1,59 @@
+// Code generated by gen from k8s-cluster.yang. DO NOT EDIT.
+
+package model
+
+// K8sClustersInfoDocument is the body of a request on the k8s-clusters-info container.
+type K8sClustersInfoDocument struct {
+	K8sClustersInfo *K8sClustersInfo `json:"k8s-cluster:k8s-clusters-info,omitempty"`
+}
+
+// K8sClusterList is the body of a request on a single k8s-clusters list entry.
+type K8sClusterList struct {
+	K8sClusters []K8sCluster `json:"k8s-cluster:k8s-clusters"`
+}
+
+// K8sClustersInfo is the k8s-clusters-info container of the k8s-cluster module.
+// Kubernetes Cluster information
+type K8sClustersInfo struct {
+	// List of Kubernetes cluster.
+	K8sClusters []K8sCluster `json:"k8s-clusters,omitempty"`
+}
+
+// Validate checks the k8s-clusters-info container against the k8s-cluster module.
+func (x *K8sClustersInfo) Validate() error {
+	seenK8sClusters := make(map[string]bool, len(x.K8sClusters))
+	for i := range x.K8sClusters {
+		if err := x.K8sClusters[i].Validate(); err != nil {
+			return nestedEntry("k8s-clusters", i, err)
+		}
+		key := x.K8sClusters[i].ListKey()
+		if seenK8sClusters[key] {
+			return duplicate("k8s-clusters", key)
+		}
+		seenK8sClusters[key] = true
+	}
+	return nil
+}
+
+// K8sCluster is the k8s-clusters list of the k8s-cluster module. List of
+// Kubernetes cluster.
+type K8sCluster struct {
+	// UUID representing the K8s cluster.
+	ClusterID string `json:"cluster-id"`
+}
+
+// ListKey returns the key of the k8s-clusters list entry.
+func (x *K8sCluster) ListKey() string {
+	return x.ClusterID
+}
+
+// Validate checks the k8s-clusters list against the k8s-cluster module.
+func (x *K8sCluster) Validate() error {
+	if x.ClusterID == "" {
+		return missing("cluster-id")
+	}
+	if err := validateUUID("cluster-id", x.ClusterID); err != nil {
+		return err
+	}
+	return nil
+}
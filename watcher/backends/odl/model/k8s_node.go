@@ -0,0 +1,243 @@
+// Code generated by gen from k8s-node.yang. DO NOT EDIT.
+
+package model
+
+// K8sNodesInfoDocument is the body of a request on the k8s-nodes-info container.
+type K8sNodesInfoDocument struct {
+	K8sNodesInfo *K8sNodesInfo `json:"k8s-node:k8s-nodes-info,omitempty"`
+}
+
+// K8sNodeList is the body of a request on a single k8s-nodes list entry.
+type K8sNodeList struct {
+	K8sNodes []K8sNode `json:"k8s-node:k8s-nodes"`
+}
+
+// K8sNodesInfo is the k8s-nodes-info container of the k8s-node module.
+// Kubernetes Nodes information
+type K8sNodesInfo struct {
+	// Kubernetes node's data tree ID
+	ID string `json:"id,omitempty"`
+	// List of Kubernetes nodes.
+	K8sNodes []K8sNode `json:"k8s-nodes,omitempty"`
+}
+
+// Validate checks the k8s-nodes-info container against the k8s-node module.
+func (x *K8sNodesInfo) Validate() error {
+	seenK8sNodes := make(map[string]bool, len(x.K8sNodes))
+	for i := range x.K8sNodes {
+		if err := x.K8sNodes[i].Validate(); err != nil {
+			return nestedEntry("k8s-nodes", i, err)
+		}
+		key := x.K8sNodes[i].ListKey()
+		if seenK8sNodes[key] {
+			return duplicate("k8s-nodes", key)
+		}
+		seenK8sNodes[key] = true
+	}
+	return nil
+}
+
+// K8sNode is the k8s-nodes list of the k8s-node module. List of Kubernetes
+// nodes.
+type K8sNode struct {
+	// UUID representing the K8s node.
+	UID string `json:"uid"`
+	// UUID representing the K8s cluster.
+	ClusterID string `json:"cluster-id,omitempty"`
+	// The hostname as reported by the node’s kernel.
+	HostName string `json:"host-name,omitempty"`
+	// The IP address of the node that is externally routable.
+	ExternalIPAddress string `json:"external-ip-address,omitempty"`
+	// The IP address of the node that is routable only within the k8s cluster. We
+	// assume that this IP address is reachable by OpenDaylight and it will be used
+	// to set the default configurations.
+	InternalIPAddress string `json:"internal-ip-address,omitempty"`
+	// PodCIDR represents the pod IP range assigned to the node.
+	PodCIDR string `json:"pod-cidr,omitempty"`
+	// The pod IP ranges assigned to the node, one per IP family on dual-stack
+	// clusters. The first entry always matches pod-cidr.
+	PodCIDRs []string `json:"pod-cidrs,omitempty"`
+	// Every address reported by the node, including the DNS names.
+	NodeAddresses []K8sNodeAddress `json:"node-addresses,omitempty"`
+	// True when the node's Ready condition is True. Nodes that are not ready
+	// should not be used as tunnel endpoints.
+	Ready bool `json:"ready"`
+	// The node conditions as reported by the kubelet.
+	Conditions []K8sNodeCondition `json:"conditions,omitempty"`
+	// Labels attached to the node.
+	Labels []K8sNodeLabel `json:"labels,omitempty"`
+	// Taints applied to the node.
+	Taints []K8sNodeTaint `json:"taints,omitempty"`
+	// The IP address used as the node's OVS tunnel endpoint, taken from the
+	// coe.opendaylight.org/tunnel-endpoint node annotation.
+	TunnelEndpointIPAddress string `json:"tunnel-endpoint-ip-address,omitempty"`
+}
+
+// ListKey returns the key of the k8s-nodes list entry.
+func (x *K8sNode) ListKey() string {
+	return x.UID
+}
+
+// Validate checks the k8s-nodes list against the k8s-node module.
+func (x *K8sNode) Validate() error {
+	if x.UID == "" {
+		return missing("uid")
+	}
+	if err := validateUUID("uid", x.UID); err != nil {
+		return err
+	}
+	if err := validateUUID("cluster-id", x.ClusterID); err != nil {
+		return err
+	}
+	if err := validateIPAddress("external-ip-address", x.ExternalIPAddress); err != nil {
+		return err
+	}
+	if err := validateIPAddress("internal-ip-address", x.InternalIPAddress); err != nil {
+		return err
+	}
+	seenNodeAddresses := make(map[string]bool, len(x.NodeAddresses))
+	for i := range x.NodeAddresses {
+		if err := x.NodeAddresses[i].Validate(); err != nil {
+			return nestedEntry("node-addresses", i, err)
+		}
+		key := x.NodeAddresses[i].ListKey()
+		if seenNodeAddresses[key] {
+			return duplicate("node-addresses", key)
+		}
+		seenNodeAddresses[key] = true
+	}
+	seenConditions := make(map[string]bool, len(x.Conditions))
+	for i := range x.Conditions {
+		if err := x.Conditions[i].Validate(); err != nil {
+			return nestedEntry("conditions", i, err)
+		}
+		key := x.Conditions[i].ListKey()
+		if seenConditions[key] {
+			return duplicate("conditions", key)
+		}
+		seenConditions[key] = true
+	}
+	seenLabels := make(map[string]bool, len(x.Labels))
+	for i := range x.Labels {
+		if err := x.Labels[i].Validate(); err != nil {
+			return nestedEntry("labels", i, err)
+		}
+		key := x.Labels[i].ListKey()
+		if seenLabels[key] {
+			return duplicate("labels", key)
+		}
+		seenLabels[key] = true
+	}
+	seenTaints := make(map[string]bool, len(x.Taints))
+	for i := range x.Taints {
+		if err := x.Taints[i].Validate(); err != nil {
+			return nestedEntry("taints", i, err)
+		}
+		key := x.Taints[i].ListKey()
+		if seenTaints[key] {
+			return duplicate("taints", key)
+		}
+		seenTaints[key] = true
+	}
+	if err := validateIPAddress("tunnel-endpoint-ip-address", x.TunnelEndpointIPAddress); err != nil {
+		return err
+	}
+	return nil
+}
+
+// K8sNodeAddress is the node-addresses list of the k8s-node module. Every
+// address reported by the node, including the DNS names.
+type K8sNodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// ListKey returns the key of the node-addresses list entry.
+func (x *K8sNodeAddress) ListKey() string {
+	return x.Type + " " + x.Address
+}
+
+// Validate checks the node-addresses list against the k8s-node module.
+func (x *K8sNodeAddress) Validate() error {
+	if x.Type == "" {
+		return missing("type")
+	}
+	if err := validateEnum("type", x.Type, "Hostname", "InternalIP", "ExternalIP", "InternalDNS", "ExternalDNS"); err != nil {
+		return err
+	}
+	if x.Address == "" {
+		return missing("address")
+	}
+	return nil
+}
+
+// K8sNodeCondition is the conditions list of the k8s-node module. The node
+// conditions as reported by the kubelet.
+type K8sNodeCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ListKey returns the key of the conditions list entry.
+func (x *K8sNodeCondition) ListKey() string {
+	return x.Type
+}
+
+// Validate checks the conditions list against the k8s-node module.
+func (x *K8sNodeCondition) Validate() error {
+	if x.Type == "" {
+		return missing("type")
+	}
+	if err := validateEnum("status", x.Status, "True", "False", "Unknown"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// K8sNodeLabel is the labels list of the k8s-node module. Labels attached to
+// the node.
+type K8sNodeLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// ListKey returns the key of the labels list entry.
+func (x *K8sNodeLabel) ListKey() string {
+	return x.Key
+}
+
+// Validate checks the labels list against the k8s-node module.
+func (x *K8sNodeLabel) Validate() error {
+	if x.Key == "" {
+		return missing("key")
+	}
+	return nil
+}
+
+// K8sNodeTaint is the taints list of the k8s-node module. Taints applied to the
+// node.
+type K8sNodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// ListKey returns the key of the taints list entry.
+func (x *K8sNodeTaint) ListKey() string {
+	return x.Key + " " + x.Effect
+}
+
+// Validate checks the taints list against the k8s-node module.
+func (x *K8sNodeTaint) Validate() error {
+	if x.Key == "" {
+		return missing("key")
+	}
+	if x.Effect == "" {
+		return missing("effect")
+	}
+	if err := validateEnum("effect", x.Effect, "NoSchedule", "PreferNoSchedule", "NoExecute"); err != nil {
+		return err
+	}
+	return nil
+}
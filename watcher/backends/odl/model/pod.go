@@ -0,0 +1,199 @@
+// Code generated by gen from pod.yang. DO NOT EDIT.
+
+package model
+
+// CoeDocument is the body of a request on the coe container.
+type CoeDocument struct {
+	Coe *Coe `json:"pod:coe,omitempty"`
+}
+
+// PodList is the body of a request on a single pods list entry.
+type PodList struct {
+	Pods []Pod `json:"pod:pods"`
+}
+
+// Coe is the coe container of the pod module. Container Configuration
+// Parameters.
+type Coe struct {
+	// List of all configured pods in the network.
+	Pods []Pod `json:"pods,omitempty"`
+}
+
+// Validate checks the coe container against the pod module.
+func (x *Coe) Validate() error {
+	seenPods := make(map[string]bool, len(x.Pods))
+	for i := range x.Pods {
+		if err := x.Pods[i].Validate(); err != nil {
+			return nestedEntry("pods", i, err)
+		}
+		key := x.Pods[i].ListKey()
+		if seenPods[key] {
+			return duplicate("pods", key)
+		}
+		seenPods[key] = true
+	}
+	return nil
+}
+
+// Pod is the pods list of the pod module. List of all configured pods in the
+// network.
+type Pod struct {
+	// UUID representing the pod.
+	UID string `json:"uid"`
+	// The pod name as reported by Kubernetes.
+	Name string `json:"name,omitempty"`
+	// IP address of k8s node.
+	HostIPAddress string `json:"host-ip-address,omitempty"`
+	// UUID representing the K8s cluster.
+	ClusterID string `json:"cluster-id,omitempty"`
+	// Network namespace defines the space for the pod. The empty namespace is
+	// equivalent to the default namespace.
+	NetworkNS string `json:"network-NS,omitempty"`
+	// MAC address of the associated port.
+	PortMACAddress string `json:"port-mac-address,omitempty"`
+	// Name of the k8s node the pod is scheduled on.
+	NodeName string `json:"node-name,omitempty"`
+	// The pod lifecycle phase as reported by Kubernetes.
+	Phase string `json:"phase,omitempty"`
+	// True when the pod is able to serve requests.
+	Ready bool `json:"ready"`
+	// Labels attached to the pod, used by network policy selectors.
+	Labels []PodLabel `json:"labels,omitempty"`
+	// Annotations attached to the pod.
+	Annotations []PodAnnotation `json:"annotations,omitempty"`
+	Interface   []PodInterface  `json:"interface,omitempty"`
+}
+
+// ListKey returns the key of the pods list entry.
+func (x *Pod) ListKey() string {
+	return x.UID
+}
+
+// Validate checks the pods list against the pod module.
+func (x *Pod) Validate() error {
+	if x.UID == "" {
+		return missing("uid")
+	}
+	if err := validateUUID("uid", x.UID); err != nil {
+		return err
+	}
+	if err := validateIPAddress("host-ip-address", x.HostIPAddress); err != nil {
+		return err
+	}
+	if err := validateUUID("cluster-id", x.ClusterID); err != nil {
+		return err
+	}
+	if err := validateEnum("phase", x.Phase, "Pending", "Running", "Succeeded", "Failed", "Unknown"); err != nil {
+		return err
+	}
+	seenLabels := make(map[string]bool, len(x.Labels))
+	for i := range x.Labels {
+		if err := x.Labels[i].Validate(); err != nil {
+			return nestedEntry("labels", i, err)
+		}
+		key := x.Labels[i].ListKey()
+		if seenLabels[key] {
+			return duplicate("labels", key)
+		}
+		seenLabels[key] = true
+	}
+	seenAnnotations := make(map[string]bool, len(x.Annotations))
+	for i := range x.Annotations {
+		if err := x.Annotations[i].Validate(); err != nil {
+			return nestedEntry("annotations", i, err)
+		}
+		key := x.Annotations[i].ListKey()
+		if seenAnnotations[key] {
+			return duplicate("annotations", key)
+		}
+		seenAnnotations[key] = true
+	}
+	seenInterface := make(map[string]bool, len(x.Interface))
+	for i := range x.Interface {
+		if err := x.Interface[i].Validate(); err != nil {
+			return nestedEntry("interface", i, err)
+		}
+		key := x.Interface[i].ListKey()
+		if seenInterface[key] {
+			return duplicate("interface", key)
+		}
+		seenInterface[key] = true
+	}
+	return nil
+}
+
+// PodLabel is the labels list of the pod module. Labels attached to the pod,
+// used by network policy selectors.
+type PodLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// ListKey returns the key of the labels list entry.
+func (x *PodLabel) ListKey() string {
+	return x.Key
+}
+
+// Validate checks the labels list against the pod module.
+func (x *PodLabel) Validate() error {
+	if x.Key == "" {
+		return missing("key")
+	}
+	return nil
+}
+
+// PodAnnotation is the annotations list of the pod module. Annotations attached
+// to the pod.
+type PodAnnotation struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// ListKey returns the key of the annotations list entry.
+func (x *PodAnnotation) ListKey() string {
+	return x.Key
+}
+
+// Validate checks the annotations list against the pod module.
+func (x *PodAnnotation) Validate() error {
+	if x.Key == "" {
+		return missing("key")
+	}
+	return nil
+}
+
+// PodInterface is the interface list of the pod module.
+type PodInterface struct {
+	// UUID representing the interface within a pod.
+	UID string `json:"uid"`
+	// IP address assigned by IPAM module.
+	IPAddress string `json:"ip-address,omitempty"`
+	// UUID representing the network.
+	NetworkID   string `json:"network-id,omitempty"`
+	NetworkType string `json:"network-type,omitempty"`
+}
+
+// ListKey returns the key of the interface list entry.
+func (x *PodInterface) ListKey() string {
+	return x.UID
+}
+
+// Validate checks the interface list against the pod module.
+func (x *PodInterface) Validate() error {
+	if x.UID == "" {
+		return missing("uid")
+	}
+	if err := validateUUID("uid", x.UID); err != nil {
+		return err
+	}
+	if err := validateIPAddress("ip-address", x.IPAddress); err != nil {
+		return err
+	}
+	if err := validateUUID("network-id", x.NetworkID); err != nil {
+		return err
+	}
+	if err := validateEnum("network-type", x.NetworkType, "FLAT", "VLAN", "VXLAN", "GRE"); err != nil {
+		return err
+	}
+	return nil
+}
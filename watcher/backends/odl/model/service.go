@@ -0,0 +1,229 @@
+// Code generated by gen from service.yang. DO NOT EDIT.
+
+package model
+
+// ServiceInformationDocument is the body of a request on the service-information container.
+type ServiceInformationDocument struct {
+	ServiceInformation *ServiceInformation `json:"service:service-information,omitempty"`
+}
+
+// ServiceList is the body of a request on a single services list entry.
+type ServiceList struct {
+	Services []Service `json:"service:services"`
+}
+
+// ServiceInformation is the service-information container of the service
+// module. Service container configuration.
+type ServiceInformation struct {
+	// List of all configured services.
+	Services []Service `json:"services,omitempty"`
+}
+
+// Validate checks the service-information container against the service module.
+func (x *ServiceInformation) Validate() error {
+	seenServices := make(map[string]bool, len(x.Services))
+	for i := range x.Services {
+		if err := x.Services[i].Validate(); err != nil {
+			return nestedEntry("services", i, err)
+		}
+		key := x.Services[i].ListKey()
+		if seenServices[key] {
+			return duplicate("services", key)
+		}
+		seenServices[key] = true
+	}
+	return nil
+}
+
+// Service is the services list of the service module. List of all configured
+// services.
+type Service struct {
+	// UUID representing the service.
+	UID string `json:"uid"`
+	// The service name as reported by Kubernetes.
+	Name string `json:"name,omitempty"`
+	// UUID representing the K8s cluster
+	ClusterID string `json:"cluster-id,omitempty"`
+	// Front-end IP address for all the pods tagged under the service.
+	ClusterIPAddress string `json:"cluster-ip-address,omitempty"`
+	// Network namespace defines the space for the service. The empty namespace is
+	// equivalent to the default namespace.
+	NetworkNS string `json:"network-NS,omitempty"`
+	// list of external IP Addresses that route the traffic into and from the
+	// service.
+	ExternalIPAddress []string `json:"external-ip-address,omitempty"`
+	// Only applies to Service Type: LoadBalancer. A loadBalancer will get created
+	// with the IP specified in this field.
+	LoadBalancerIP string `json:"load-balancer-IP,omitempty"`
+	// List of ingress IP addresses that are assigned to the service.
+	IngressIPAddress []string `json:"ingress-ip-address,omitempty"`
+	// List of the associated ports.
+	ServicePorts []ServicePort `json:"service-ports,omitempty"`
+}
+
+// ListKey returns the key of the services list entry.
+func (x *Service) ListKey() string {
+	return x.UID
+}
+
+// Validate checks the services list against the service module.
+func (x *Service) Validate() error {
+	if x.UID == "" {
+		return missing("uid")
+	}
+	if err := validateUUID("uid", x.UID); err != nil {
+		return err
+	}
+	if err := validateUUID("cluster-id", x.ClusterID); err != nil {
+		return err
+	}
+	if err := validateIPAddress("cluster-ip-address", x.ClusterIPAddress); err != nil {
+		return err
+	}
+	for _, value := range x.ExternalIPAddress {
+		if err := validateIPAddress("external-ip-address", value); err != nil {
+			return err
+		}
+	}
+	if err := validateIPAddress("load-balancer-IP", x.LoadBalancerIP); err != nil {
+		return err
+	}
+	for _, value := range x.IngressIPAddress {
+		if err := validateIPAddress("ingress-ip-address", value); err != nil {
+			return err
+		}
+	}
+	for i := range x.ServicePorts {
+		if err := x.ServicePorts[i].Validate(); err != nil {
+			return nestedEntry("service-ports", i, err)
+		}
+	}
+	return nil
+}
+
+// ServicePort is the service-ports list of the service module. List of the
+// associated ports.
+type ServicePort struct {
+	// The name of this port within the service. This maps to the 'Name' field in
+	// EndpointPort objects.
+	Name string `json:"name,omitempty"`
+	// The port that will be exposed by this service.
+	Port int32 `json:"port,omitempty"`
+	// Number or name of the port to access on the pods targeted by the service.
+	TargetPort string `json:"target-port,omitempty"`
+	// The port on each node on which this service is exposed when type equal to
+	// NodePort or LoadBalancer.
+	NodePort int32 `json:"node-port,omitempty"`
+}
+
+// Validate checks the service-ports list against the service module.
+func (x *ServicePort) Validate() error {
+	return nil
+}
+
+// EndpointsInfoDocument is the body of a request on the endpoints-info container.
+type EndpointsInfoDocument struct {
+	EndpointsInfo *EndpointsInfo `json:"service:endpoints-info,omitempty"`
+}
+
+// EndpointList is the body of a request on a single endpoints list entry.
+type EndpointList struct {
+	Endpoints []Endpoint `json:"service:endpoints"`
+}
+
+// EndpointsInfo is the endpoints-info container of the service module.
+// Endpoints container configuration.
+type EndpointsInfo struct {
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+}
+
+// Validate checks the endpoints-info container against the service module.
+func (x *EndpointsInfo) Validate() error {
+	seenEndpoints := make(map[string]bool, len(x.Endpoints))
+	for i := range x.Endpoints {
+		if err := x.Endpoints[i].Validate(); err != nil {
+			return nestedEntry("endpoints", i, err)
+		}
+		key := x.Endpoints[i].ListKey()
+		if seenEndpoints[key] {
+			return duplicate("endpoints", key)
+		}
+		seenEndpoints[key] = true
+	}
+	return nil
+}
+
+// Endpoint is the endpoints list of the service module.
+type Endpoint struct {
+	// UUID representing the endpoint.
+	UID string `json:"uid"`
+	// The endpoint name (should match service name).
+	Name string `json:"name,omitempty"`
+	// UUID representing the K8s cluster
+	ClusterID string `json:"cluster-id,omitempty"`
+	// Network namespace defines the space for the endpoint. The empty namespace is
+	// equivalent to the default namespace.
+	NetworkNS         string            `json:"network-NS,omitempty"`
+	EndpointAddresses []EndpointAddress `json:"endpoint-addresses,omitempty"`
+	EndpointPorts     []EndpointPort    `json:"endpoint-ports,omitempty"`
+}
+
+// ListKey returns the key of the endpoints list entry.
+func (x *Endpoint) ListKey() string {
+	return x.UID
+}
+
+// Validate checks the endpoints list against the service module.
+func (x *Endpoint) Validate() error {
+	if x.UID == "" {
+		return missing("uid")
+	}
+	if err := validateUUID("uid", x.UID); err != nil {
+		return err
+	}
+	if err := validateUUID("cluster-id", x.ClusterID); err != nil {
+		return err
+	}
+	for i := range x.EndpointAddresses {
+		if err := x.EndpointAddresses[i].Validate(); err != nil {
+			return nestedEntry("endpoint-addresses", i, err)
+		}
+	}
+	for i := range x.EndpointPorts {
+		if err := x.EndpointPorts[i].Validate(); err != nil {
+			return nestedEntry("endpoint-ports", i, err)
+		}
+	}
+	return nil
+}
+
+// EndpointAddress is the endpoint-addresses list of the service module.
+type EndpointAddress struct {
+	// The IP address of this endpoint corresponding to the Pod IP-Address.
+	IPAddress string `json:"ip-address,omitempty"`
+	// The host name of the endpoint.
+	HostName string `json:"host-name,omitempty"`
+	// Name of the node that host this endpoint.
+	NodeName string `json:"node-name,omitempty"`
+}
+
+// Validate checks the endpoint-addresses list against the service module.
+func (x *EndpointAddress) Validate() error {
+	if err := validateIPAddress("ip-address", x.IPAddress); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EndpointPort is the endpoint-ports list of the service module.
+type EndpointPort struct {
+	// Name of this port within the endpoint (should match ServicePort.Name).
+	Name string `json:"name,omitempty"`
+	// The endpoint port number.
+	Port int32 `json:"port,omitempty"`
+}
+
+// Validate checks the endpoint-ports list against the service module.
+func (x *EndpointPort) Validate() error {
+	return nil
+}
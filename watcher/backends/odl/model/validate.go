@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// Validator is implemented by every generated type.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError reports the first node of a payload violating the schema.
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// yang:uuid pattern from ietf-yang-types
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func missing(name string) error {
+	return &ValidationError{Path: name, Reason: "missing mandatory value"}
+}
+
+func duplicate(name, key string) error {
+	return &ValidationError{Path: fmt.Sprintf("%s[%s]", name, key), Reason: "duplicate list key"}
+}
+
+func nested(name string, err error) error {
+	if e, ok := err.(*ValidationError); ok {
+		return &ValidationError{Path: name + "/" + e.Path, Reason: e.Reason}
+	}
+	return err
+}
+
+func nestedEntry(name string, index int, err error) error {
+	return nested(fmt.Sprintf("%s[%d]", name, index), err)
+}
+
+func validateIPAddress(name, value string) error {
+	if value != "" && net.ParseIP(value) == nil {
+		return &ValidationError{Path: name, Reason: fmt.Sprintf("invalid ip address %q", value)}
+	}
+	return nil
+}
+
+func validateUUID(name, value string) error {
+	if value != "" && !uuidPattern.MatchString(value) {
+		return &ValidationError{Path: name, Reason: fmt.Sprintf("invalid uuid %q", value)}
+	}
+	return nil
+}
+
+func validateEnum(name, value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return &ValidationError{Path: name, Reason: fmt.Sprintf("%q is not one of %v", value, allowed)}
+}
@@ -5,180 +5,436 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"k8s.io/api/core/v1"
 
 	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl/model"
 )
 
+const (
+	defaultBatchSize = 500
+
+	yangPatchContentType = "application/yang.patch+json"
+)
+
+// Options tunes how the backend talks to ODL.
+type Options struct {
+	// BatchWindow enables the bulk mode when non zero: added objects are
+	// buffered for up to this long and then written per container in a
+	// single request.
+	BatchWindow time.Duration
+	// BatchSize is the maximum number of objects written in one request.
+	BatchSize int
+
+	// RequestTimeout bounds a whole request, including reading the answer.
+	RequestTimeout time.Duration
+	// DialTimeout bounds establishing the connection to ODL.
+	DialTimeout time.Duration
+	// MaxIdleConns and MaxIdleConnsPerHost size the connection pool.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes pooled connections unused for this long.
+	IdleConnTimeout time.Duration
+
+	// BreakerThreshold is the number of consecutive failures after which
+	// requests stop being sent to ODL and are queued instead, 0 disables it.
+	BreakerThreshold int
+	// BreakerCooldown is the delay between two probes of an unavailable ODL.
+	BreakerCooldown time.Duration
+	// QueueSize bounds the number of requests queued while ODL is down.
+	QueueSize int
+	// Resync is called once ODL answers again after the circuit opened or
+	// the queue overflowed. It is expected to add every object again.
+	Resync func()
+}
+
+// DefaultOptions returns the options used when nothing is configured.
+func DefaultOptions() Options {
+	return Options{
+		BatchSize:           defaultBatchSize,
+		RequestTimeout:      30 * time.Second,
+		DialTimeout:         5 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		BreakerThreshold:    defaultBreakerThreshold,
+		BreakerCooldown:     defaultBreakerCooldown,
+		QueueSize:           defaultQueueSize,
+	}
+}
+
 type backend struct {
 	client    *http.Client
 	clusterId string
 	urlPrefix string
-	username  string
-	password  string
+	// credentials are shared by the copies of the backend, so that they
+	// can be changed while it runs
+	credentials *credentials
+	batch       *batcher
+	breaker     *breaker
+}
+
+// Backend is the ODL implementation of backends.Coe.
+type Backend interface {
+	backends.Coe
+	backends.Flusher
+
+	// AddCluster registers the watched cluster in ODL.
+	AddCluster() error
+	// SetCredentials changes the credentials used by the next requests.
+	SetCredentials(credentials Credentials)
+
+	// Pods, Nodes, Services, Endpoints and Clusters read back what ODL
+	// holds in its config datastore.
+	Pods() ([]model.Pod, error)
+	Nodes() ([]model.K8sNode, error)
+	Services() ([]model.Service, error)
+	Endpoints() ([]model.Endpoint, error)
+	Clusters() ([]model.K8sCluster, error)
 }
 
-func New(url, username, password string) backends.Coe {
+// New returns a backend writing to the ODL at url. It does not contact ODL,
+// AddCluster has to be called before the first object is written.
+func New(url, username, password string, options Options) Backend {
 	service := backend{
-		client:    &http.Client{},
-		username:  username,
-		password:  password,
+		client: newClient(options),
+		credentials: &credentials{
+			current: Credentials{Username: username, Password: password},
+		},
 		urlPrefix: url,
 		// TODO Fill this out when cluster-registry work is complete upstream
 		clusterId: "00000000-0000-0000-0000-000000000001",
 	}
-
-	err := service.AddCluster()
-	if err != nil {
-		log.Printf("unable to create cluster in odl: %s\n", err.Error())
+	if options.BatchWindow > 0 {
+		service.batch = newBatcher(options.BatchWindow, options.BatchSize, service.putBatch)
+	}
+	if options.BreakerThreshold > 0 {
+		service.breaker = newBreaker(options.BreakerThreshold, options.BreakerCooldown, options.QueueSize,
+			service.send, service.probe, options.Resync)
 	}
 	return service
 }
 
+func newClient(options Options) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   options.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Timeout: options.RequestTimeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: options.DialTimeout,
+			MaxIdleConns:        options.MaxIdleConns,
+			MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+			IdleConnTimeout:     options.IdleConnTimeout,
+		},
+	}
+}
+
 func (b backend) AddCluster() error {
-	js := createClusterStructure(b.clusterId)
-	createClusterStructure(b.clusterId)
+	js, err := json.Marshal(createClusterStructure(b.clusterId))
+	if err != nil {
+		return err
+	}
 	return b.putCluster(js)
 }
 
-func createClusterStructure(clusterId string) []byte {
-	clusters := []Cluster{
-		{ClusterID: clusterId},
-	}
-	clusterContainer := ClusterContainer{
-		Clusters: clusters,
+func createClusterStructure(clusterId string) model.K8sClustersInfoDocument {
+	return model.K8sClustersInfoDocument{
+		K8sClustersInfo: &model.K8sClustersInfo{
+			K8sClusters: []model.K8sCluster{
+				{ClusterID: clusterId},
+			},
+		},
 	}
-	cluster := Coe{
-		Clusters: &clusterContainer,
-		Pods: nil,
-	}
-
-	res, _ := json.Marshal(cluster)
-
-	return res
 }
 
 func (b backend) AddPod(pod *v1.Pod) error {
-	js := createPodStructure(pod, b.clusterId)
-	return b.putPod(string(pod.GetUID()), js)
+	return b.add(podsContainer, string(pod.GetUID()), createOdlPod(pod, b.clusterId))
 }
 
 func (b backend) UpdatePod(old, new *v1.Pod) error {
-	newJs := createPodStructure(new, b.clusterId)
-	return b.putPod(string(new.GetUID()), newJs)
+	return b.update(podsContainer, string(new.GetUID()),
+		createOdlPod(old, b.clusterId), createOdlPod(new, b.clusterId))
 }
 
 func (b backend) DeletePod(pod *v1.Pod) error {
-	return b.deletePod(string(pod.GetUID()))
+	return b.delete(podsContainer, string(pod.GetUID()))
 }
 
 func (b backend) AddNode(node *v1.Node) error {
-	js := createNodeStructure(node, b.clusterId)
-	return b.putNode(string(node.GetUID()), js)
+	return b.add(nodesContainer, string(node.GetUID()), createOdlNode(node, b.clusterId))
 }
 
 func (b backend) UpdateNode(old, new *v1.Node) error {
-	newJs := createNodeStructure(new, b.clusterId)
-	return b.putNode(string(new.GetUID()), newJs)
+	return b.update(nodesContainer, string(new.GetUID()),
+		createOdlNode(old, b.clusterId), createOdlNode(new, b.clusterId))
 }
 
 func (b backend) DeleteNode(node *v1.Node) error {
-	return b.deleteNode(string(node.GetUID()))
+	return b.delete(nodesContainer, string(node.GetUID()))
 }
 
 func (b backend) AddService(service *v1.Service) error {
-	js := createServiceStructure(service, b.clusterId)
-	return b.putService(string(service.GetUID()), js)
+	return b.add(servicesContainer, string(service.GetUID()), createOdlService(service, b.clusterId))
 }
 
 func (b backend) UpdateService(old, new *v1.Service) error {
-	newJs := createServiceStructure(new, b.clusterId)
-	return b.putService(string(new.GetUID()), newJs)
+	return b.update(servicesContainer, string(new.GetUID()),
+		createOdlService(old, b.clusterId), createOdlService(new, b.clusterId))
 }
 
 func (b backend) DeleteService(service *v1.Service) error {
-	return b.deleteService(string(service.GetUID()))
+	return b.delete(servicesContainer, string(service.GetUID()))
 }
 
 func (b backend) AddEndpoints(endpoints *v1.Endpoints) error {
-	js := createEndpointStructure(endpoints, b.clusterId)
-	return b.putEndpoints(string(endpoints.GetUID()), js)
+	return b.add(endpointsContainer, string(endpoints.GetUID()), createOdlEndpoints(endpoints, b.clusterId))
 }
 
 func (b backend) UpdateEndpoints(old, new *v1.Endpoints) error {
-	newJs := createEndpointStructure(new, b.clusterId)
-	log.Println(newJs)
-	return b.putEndpoints(string(new.GetUID()), newJs)
+	return b.update(endpointsContainer, string(new.GetUID()),
+		createOdlEndpoints(old, b.clusterId), createOdlEndpoints(new, b.clusterId))
 }
 
 func (b backend) DeleteEndpoints(endpoints *v1.Endpoints) error {
-	return b.deleteEndpoints(string(endpoints.GetUID()))
+	return b.delete(endpointsContainer, string(endpoints.GetUID()))
+}
+
+func (b backend) SetCredentials(credentials Credentials) {
+	b.credentials.set(credentials)
+}
+
+// Flush sends the objects buffered by the bulk mode, if any.
+func (b backend) Flush() {
+	if b.batch != nil {
+		b.batch.flush()
+	}
+}
+
+// add validates the entry against the schema and writes it, or buffers it
+// in bulk mode.
+func (b backend) add(c container, uid string, entry model.Validator) error {
+	if err := entry.Validate(); err != nil {
+		log.Printf("Refusing invalid %s %s: %s\n", c.list, uid, err.Error())
+		return err
+	}
+	js := createListStructure(c, entry)
+	if b.batch != nil {
+		b.batch.add(c, uid, js)
+		return nil
+	}
+	return b.put(c, uid, js)
+}
+
+// update sends only what changed between the old and new entries as a
+// yang-patch, and falls back to a PUT of the whole new entry when the change
+// cannot be expressed as a patch or ODL rejects it.
+func (b backend) update(c container, uid string, oldEntry, newEntry model.Validator) error {
+	if err := newEntry.Validate(); err != nil {
+		log.Printf("Refusing invalid %s %s: %s\n", c.list, uid, err.Error())
+		return err
+	}
+	js := createListStructure(c, newEntry)
+	if b.batch != nil && b.batch.replace(c, uid, js) {
+		return nil
+	}
+
+	patch, changed, ok := createPatch(c, uid, oldEntry, newEntry)
+	if !changed {
+		return nil
+	}
+	if ok {
+		err := b.patch(c, patch)
+		if err == nil || isQueued(err) {
+			return err
+		}
+		log.Printf("Patching %s %s failed, falling back to a full write: %s\n", c.list, uid, err.Error())
+	}
+	return b.put(c, uid, js)
+}
+
+func (b backend) delete(c container, uid string) error {
+	if b.batch != nil {
+		b.batch.drop(c, uid)
+	}
+	return b.doRequest(http.MethodDelete, b.urlPrefix+c.listUrl+uid, nil)
+}
+
+func (b backend) put(c container, uid string, js []byte) error {
+	return b.doRequest(http.MethodPut, b.urlPrefix+c.listUrl+uid, js)
 }
 
-func (b backend) doRequest(method, url string, reader io.Reader) error {
-	log.Println(method, url)
-	req, err := http.NewRequest(method, url, reader)
+func (b backend) patch(c container, patch YangPatch) error {
+	js, err := json.Marshal(patch)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(b.username, b.password)
+	return b.doRequestWithContentType(http.MethodPatch, b.urlPrefix+c.url, yangPatchContentType, js)
+}
+
+// putBatch writes all the entries with one yang-patch request on the
+// container. ODL builds without yang-patch support reject it, in which case
+// the entries are written one by one.
+func (b backend) putBatch(c container, entries []batchEntry) {
+	patch := YangPatch{
+		Patch: YangPatchBody{
+			PatchID: fmt.Sprintf("coe-%s-%d", c.list, time.Now().UnixNano()),
+			Edits:   make([]YangPatchEdit, len(entries)),
+		},
+	}
+	for i, entry := range entries {
+		patch.Patch.Edits[i] = YangPatchEdit{
+			EditID:    strconv.Itoa(i + 1),
+			Operation: "replace",
+			Target:    "/" + c.list + "=" + entry.uid,
+			Value:     entry.js,
+		}
+	}
+	err := b.patch(c, patch)
+	if err == nil || isQueued(err) {
+		return
+	}
+	log.Printf("Bulk write of %s failed, falling back to single writes: %s\n", c.list, err.Error())
+	for _, entry := range entries {
+		if err := b.put(c, entry.uid, entry.js); err != nil {
+			log.Printf("unable to write %s %s: %s\n", c.list, entry.uid, err.Error())
+		}
+	}
+}
+
+func (b backend) doRequest(method, url string, body []byte) error {
+	return b.doRequestWithContentType(method, url, "application/json", body)
+}
+
+func (b backend) doRequestWithContentType(method, url, contentType string, body []byte) error {
+	r := request{method: method, url: url, contentType: contentType, body: body}
+	if b.breaker != nil {
+		return b.breaker.do(r)
+	}
+	return b.send(r)
+}
+
+func (b backend) send(r request) error {
+	log.Println(r.method, r.url)
+	var reader io.Reader
+	if r.body != nil {
+		reader = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequest(r.method, r.url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", r.contentType)
+	b.credentials.authorize(req)
 
 	res, err := b.client.Do(req)
 	if err != nil {
 		log.Println(err)
-		return err
+		return unavailableError{err}
 	}
 	defer res.Body.Close()
+	// drain the body so that the connection goes back to the pool
+	io.Copy(ioutil.Discard, res.Body)
 
-	if res.StatusCode != http.StatusOK {
+	if res.StatusCode >= http.StatusInternalServerError {
 		log.Println(res)
-		return fmt.Errorf("HTTP server did not respond with 200 OK: %v", res)
+		return unavailableError{fmt.Errorf("HTTP server responded with %s", res.Status)}
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		log.Println(res)
+		return fmt.Errorf("HTTP server responded with unexpected status %s", res.Status)
 	}
 
 	return nil
 }
 
-func (b backend) putPod(uid string, js []byte) error {
-	fmt.Println(string(js))
-	return b.doRequest(http.MethodPut, b.urlPrefix+PodsUrl+uid, bytes.NewBuffer(js))
+// probe checks whether ODL answers at all; any answer other than a server
+// error means it is back.
+func (b backend) probe() error {
+	err := b.send(request{method: http.MethodGet, url: b.urlPrefix + ClustersUrl, contentType: "application/json"})
+	if isUnavailable(err) {
+		return err
+	}
+	return nil
 }
 
-func (b backend) deletePod(uid string) error {
-	return b.doRequest(http.MethodDelete, b.urlPrefix+PodsUrl+uid, nil)
+func (b backend) putCluster(js []byte) error {
+	return b.doRequest(http.MethodPut, b.urlPrefix+ClustersUrl, js)
 }
 
-func (b backend) putNode(uid string, js []byte) error {
-	fmt.Println(string(js))
-	return b.doRequest(http.MethodPut, b.urlPrefix+NodesUrl+uid, bytes.NewBuffer(js))
+func (b backend) Pods() ([]model.Pod, error) {
+	var document model.CoeDocument
+	if err := b.get(podsContainer.url, &document); err != nil || document.Coe == nil {
+		return nil, err
+	}
+	return document.Coe.Pods, nil
 }
 
-func (b backend) deleteNode(uid string) error {
-	return b.doRequest(http.MethodDelete, b.urlPrefix+NodesUrl+uid, nil)
+func (b backend) Nodes() ([]model.K8sNode, error) {
+	var document model.K8sNodesInfoDocument
+	if err := b.get(nodesContainer.url, &document); err != nil || document.K8sNodesInfo == nil {
+		return nil, err
+	}
+	return document.K8sNodesInfo.K8sNodes, nil
 }
 
-func (b backend) putService(uid string, js []byte) error {
-	fmt.Println(string(js))
-	return b.doRequest(http.MethodPut, b.urlPrefix+ServicesUrl+uid, bytes.NewBuffer(js))
+func (b backend) Services() ([]model.Service, error) {
+	var document model.ServiceInformationDocument
+	if err := b.get(servicesContainer.url, &document); err != nil || document.ServiceInformation == nil {
+		return nil, err
+	}
+	return document.ServiceInformation.Services, nil
 }
 
-func (b backend) deleteService(uid string) error {
-	return b.doRequest(http.MethodDelete, b.urlPrefix+ServicesUrl+uid, nil)
+func (b backend) Endpoints() ([]model.Endpoint, error) {
+	var document model.EndpointsInfoDocument
+	if err := b.get(endpointsContainer.url, &document); err != nil || document.EndpointsInfo == nil {
+		return nil, err
+	}
+	return document.EndpointsInfo.Endpoints, nil
 }
 
-func (b backend) putEndpoints(uid string, js []byte) error {
-	fmt.Println(string(js))
-	return b.doRequest(http.MethodPut, b.urlPrefix+EndPointsUrl+uid, bytes.NewBuffer(js))
+func (b backend) Clusters() ([]model.K8sCluster, error) {
+	var document model.K8sClustersInfoDocument
+	if err := b.get(ClustersUrl, &document); err != nil || document.K8sClustersInfo == nil {
+		return nil, err
+	}
+	return document.K8sClustersInfo.K8sClusters, nil
 }
 
-func (b backend) deleteEndpoints(uid string) error {
-	return b.doRequest(http.MethodDelete, b.urlPrefix+EndPointsUrl+uid, nil)
-}
+// get reads a container from the config datastore into document. A missing
+// container, which ODL reports as 404, leaves document empty.
+func (b backend) get(url string, document interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, b.urlPrefix+url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	b.credentials.authorize(req)
 
-func (b backend) putCluster(js []byte) error {
-	return b.doRequest(http.MethodPut, b.urlPrefix+ClustersUrl, bytes.NewBuffer(js))
-}
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotFound {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, res.Body)
+		return fmt.Errorf("HTTP server responded to GET %s with %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(document)
+}
@@ -0,0 +1,594 @@
+package odl
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl/fake"
+)
+
+const (
+	podUID      = "11111111-1111-1111-1111-111111111111"
+	serviceUID  = "22222222-2222-2222-2222-222222222222"
+	endpointUID = "33333333-3333-3333-3333-333333333333"
+	nodeUID     = "44444444-4444-4444-4444-444444444444"
+)
+
+// newTestBackend returns a backend writing to a fresh fake server, which is
+// closed at the end of the test.
+func newTestBackend(t *testing.T, options Options) (*fake.Server, backend) {
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+	return server, New(server.URL, "admin", "admin", options).(backend)
+}
+
+// directOptions disables the bulk mode and the breaker, so that every call
+// is a single request.
+func directOptions() Options {
+	options := DefaultOptions()
+	options.BreakerThreshold = 0
+	return options
+}
+
+// storedLeaf returns the JSON value of a leaf of the entry held by the fake.
+func storedLeaf(t *testing.T, server *fake.Server, c container, uid, leaf string) (string, bool) {
+	t.Helper()
+	entry, ok := server.Entry(strings.TrimPrefix(c.url, "/restconf/config/"), c.list, uid)
+	if !ok {
+		t.Fatalf("%s %s is not stored", c.list, uid)
+	}
+	value, ok := entry[leaf]
+	return string(value), ok
+}
+
+func stored(server *fake.Server, c container) []string {
+	return server.Keys(strings.TrimPrefix(c.url, "/restconf/config/"), c.list)
+}
+
+func lastRequest(t *testing.T, server *fake.Server) fake.Request {
+	t.Helper()
+	requests := server.Requests()
+	if len(requests) == 0 {
+		t.Fatal("no request was sent")
+	}
+	return requests[len(requests)-1]
+}
+
+func testPod(uid, phase, hostIP string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid), Name: "web", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{Phase: v1.PodPhase(phase), HostIP: hostIP, PodIP: "10.11.1.2"},
+	}
+}
+
+func testService(clusterIP string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{UID: serviceUID, Name: "web", Namespace: "default"},
+		Spec: v1.ServiceSpec{
+			ClusterIP: clusterIP,
+			Ports:     []v1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)}},
+		},
+	}
+}
+
+func testEndpoints(ips ...string) *v1.Endpoints {
+	subset := v1.EndpointSubset{Ports: []v1.EndpointPort{{Name: "http", Port: 8080}}}
+	for _, ip := range ips {
+		subset.Addresses = append(subset.Addresses, v1.EndpointAddress{IP: ip})
+	}
+	return &v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{UID: endpointUID, Name: "web", Namespace: "default"},
+		Subsets:    []v1.EndpointSubset{subset},
+	}
+}
+
+func testNode(internalIP string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{UID: nodeUID, Name: "node-1"},
+		Spec:       v1.NodeSpec{PodCIDR: "10.11.1.0/24"},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+			{Type: v1.NodeHostName, Address: "node-1"},
+			{Type: v1.NodeInternalIP, Address: internalIP},
+		}},
+	}
+}
+
+// Each kind is added, updated and deleted, checking what the fake stores
+// and whether the update went out as a yang-patch or a full write.
+func TestAddUpdateDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      container
+		uid    string
+		add    func(b backend) error
+		update func(b backend) error
+		delete func(b backend) error
+		// leaf changed by the update and its new value
+		leaf  string
+		value string
+		// method the update is expected to use
+		method string
+	}{
+		{
+			name: "pod",
+			c:    podsContainer,
+			uid:  podUID,
+			add:  func(b backend) error { return b.AddPod(testPod(podUID, "Pending", "192.168.1.1")) },
+			update: func(b backend) error {
+				return b.UpdatePod(testPod(podUID, "Pending", "192.168.1.1"), testPod(podUID, "Running", "192.168.1.1"))
+			},
+			delete: func(b backend) error { return b.DeletePod(testPod(podUID, "Running", "192.168.1.1")) },
+			leaf:   "phase",
+			value:  `"Running"`,
+			method: http.MethodPatch,
+		},
+		{
+			name:   "service",
+			c:      servicesContainer,
+			uid:    serviceUID,
+			add:    func(b backend) error { return b.AddService(testService("10.96.0.10")) },
+			update: func(b backend) error { return b.UpdateService(testService("10.96.0.10"), testService("10.96.0.20")) },
+			delete: func(b backend) error { return b.DeleteService(testService("10.96.0.20")) },
+			leaf:   "cluster-ip-address",
+			value:  `"10.96.0.20"`,
+			method: http.MethodPatch,
+		},
+		{
+			name: "endpoints",
+			c:    endpointsContainer,
+			uid:  endpointUID,
+			add:  func(b backend) error { return b.AddEndpoints(testEndpoints("10.11.1.2")) },
+			update: func(b backend) error {
+				return b.UpdateEndpoints(testEndpoints("10.11.1.2"), testEndpoints("10.11.1.2", "10.11.2.2"))
+			},
+			delete: func(b backend) error { return b.DeleteEndpoints(testEndpoints("10.11.1.2", "10.11.2.2")) },
+			leaf:   "endpoint-addresses",
+			value:  `[{"ip-address":"10.11.1.2"},{"ip-address":"10.11.2.2"}]`,
+			method: http.MethodPut,
+		},
+		{
+			name:   "node",
+			c:      nodesContainer,
+			uid:    nodeUID,
+			add:    func(b backend) error { return b.AddNode(testNode("192.168.1.1")) },
+			update: func(b backend) error { return b.UpdateNode(testNode("192.168.1.1"), testNode("192.168.1.2")) },
+			delete: func(b backend) error { return b.DeleteNode(testNode("192.168.1.2")) },
+			leaf:   "internal-ip-address",
+			value:  `"192.168.1.2"`,
+			method: http.MethodPut,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, b := newTestBackend(t, directOptions())
+
+			if err := test.add(b); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if r := lastRequest(t, server); r.Method != http.MethodPut || r.Path != test.c.listUrl+test.uid {
+				t.Errorf("add sent %s %s, expected a PUT of %s", r.Method, r.Path, test.c.listUrl+test.uid)
+			}
+			if value, _ := storedLeaf(t, server, test.c, test.uid, "cluster-id"); value != `"`+b.clusterId+`"` {
+				t.Errorf("stored cluster-id %s, expected %s", value, b.clusterId)
+			}
+
+			if err := test.update(b); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			if r := lastRequest(t, server); r.Method != test.method {
+				t.Errorf("update sent %s %s, expected a %s", r.Method, r.Path, test.method)
+			}
+			if value, _ := storedLeaf(t, server, test.c, test.uid, test.leaf); value != test.value {
+				t.Errorf("stored %s %s, expected %s", test.leaf, value, test.value)
+			}
+
+			if err := test.delete(b); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if r := lastRequest(t, server); r.Method != http.MethodDelete || r.Path != test.c.listUrl+test.uid {
+				t.Errorf("delete sent %s %s, expected a DELETE of %s", r.Method, r.Path, test.c.listUrl+test.uid)
+			}
+			if keys := stored(server, test.c); len(keys) != 0 {
+				t.Errorf("%s left in ODL after the delete", keys)
+			}
+		})
+	}
+}
+
+// An update which changes nothing sends nothing.
+func TestUpdateUnchanged(t *testing.T) {
+	server, b := newTestBackend(t, directOptions())
+	pod := testPod(podUID, "Running", "192.168.1.1")
+	if err := b.UpdatePod(pod, pod); err != nil {
+		t.Fatal(err)
+	}
+	if requests := server.Requests(); len(requests) != 0 {
+		t.Errorf("sent %d requests for an unchanged pod", len(requests))
+	}
+}
+
+// The entries written are read back by the getters.
+func TestReadBack(t *testing.T) {
+	_, b := newTestBackend(t, directOptions())
+	if err := b.AddPod(testPod(podUID, "Running", "192.168.1.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddNode(testNode("192.168.1.1")); err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := b.Pods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].UID != podUID || pods[0].Phase != "Running" {
+		t.Errorf("Pods() = %+v", pods)
+	}
+	nodes, err := b.Nodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].UID != nodeUID || nodes[0].InternalIPAddress != "192.168.1.1" {
+		t.Errorf("Nodes() = %+v", nodes)
+	}
+	services, err := b.Services()
+	if err != nil || len(services) != 0 {
+		t.Errorf("Services() = %+v, %v, expected none", services, err)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     *v1.Pod
+		new     *v1.Pod
+		changed bool
+		ok      bool
+		// operation and target of each expected edit
+		edits []string
+		// merged leaves, besides the uid
+		merged map[string]string
+	}{
+		{
+			name:    "unchanged",
+			old:     testPod(podUID, "Running", "192.168.1.1"),
+			new:     testPod(podUID, "Running", "192.168.1.1"),
+			changed: false,
+			ok:      true,
+		},
+		{
+			name:    "leaf changed",
+			old:     testPod(podUID, "Pending", "192.168.1.1"),
+			new:     testPod(podUID, "Running", "192.168.1.1"),
+			changed: true,
+			ok:      true,
+			edits:   []string{"merge /pods=" + podUID},
+			merged:  map[string]string{"phase": `"Running"`},
+		},
+		{
+			name:    "leaf removed",
+			old:     testPod(podUID, "Running", "192.168.1.1"),
+			new:     testPod(podUID, "Running", ""),
+			changed: true,
+			ok:      true,
+			edits:   []string{"remove /pods=" + podUID + "/host-ip-address"},
+		},
+		{
+			name:    "leaf changed and removed",
+			old:     testPod(podUID, "Pending", "192.168.1.1"),
+			new:     testPod(podUID, "Running", ""),
+			changed: true,
+			ok:      true,
+			edits:   []string{"merge /pods=" + podUID, "remove /pods=" + podUID + "/host-ip-address"},
+			merged:  map[string]string{"phase": `"Running"`},
+		},
+		{
+			name: "nested list changed",
+			old:  testPod(podUID, "Running", "192.168.1.1"),
+			new: func() *v1.Pod {
+				pod := testPod(podUID, "Running", "192.168.1.1")
+				pod.Status.PodIP = "10.11.1.3"
+				return pod
+			}(),
+			changed: true,
+			ok:      false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patch, changed, ok := createPatch(podsContainer, podUID,
+				createOdlPod(test.old, "cluster"), createOdlPod(test.new, "cluster"))
+			if changed != test.changed || ok != test.ok {
+				t.Fatalf("createPatch() changed %t ok %t, expected %t and %t", changed, ok, test.changed, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if len(patch.Patch.Edits) != len(test.edits) {
+				t.Fatalf("edits %+v, expected %v", patch.Patch.Edits, test.edits)
+			}
+			for i, edit := range patch.Patch.Edits {
+				if got := edit.Operation + " " + edit.Target; got != test.edits[i] {
+					t.Errorf("edit %d is %s, expected %s", i, got, test.edits[i])
+				}
+				if edit.Operation != "merge" {
+					continue
+				}
+				leaves := mergedLeaves(t, podsContainer, YangPatch{Patch: YangPatchBody{Edits: []YangPatchEdit{edit}}})
+				if len(leaves) != len(test.merged)+1 {
+					t.Errorf("merged %d leaves, expected uid and %v", len(leaves), test.merged)
+				}
+				if string(leaves["uid"]) != `"`+podUID+`"` {
+					t.Errorf("merge has uid %s", leaves["uid"])
+				}
+				for leaf, value := range test.merged {
+					if string(leaves[leaf]) != value {
+						t.Errorf("merged %s is %s, expected %s", leaf, leaves[leaf], value)
+					}
+				}
+			}
+		})
+	}
+}
+
+// A removed leaf is removed from the stored entry by the yang-patch.
+func TestUpdateRemovesLeaf(t *testing.T) {
+	server, b := newTestBackend(t, directOptions())
+	old := testPod(podUID, "Running", "192.168.1.1")
+	if err := b.AddPod(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.UpdatePod(old, testPod(podUID, "Running", "")); err != nil {
+		t.Fatal(err)
+	}
+	r := lastRequest(t, server)
+	if r.Method != http.MethodPatch || r.ContentType != yangPatchContentType || r.Path != podsContainer.url {
+		t.Errorf("update sent %s %s as %s, expected a yang-patch of the container", r.Method, r.Path, r.ContentType)
+	}
+	if value, found := storedLeaf(t, server, podsContainer, podUID, "host-ip-address"); found {
+		t.Errorf("host-ip-address %s is still stored", value)
+	}
+	if value, _ := storedLeaf(t, server, podsContainer, podUID, "phase"); value != `"Running"` {
+		t.Errorf("stored phase %s, expected it untouched", value)
+	}
+}
+
+// A rejected yang-patch falls back to a PUT of the whole entry.
+func TestUpdateFallsBackToPut(t *testing.T) {
+	server, b := newTestBackend(t, directOptions())
+	old := testPod(podUID, "Pending", "192.168.1.1")
+	if err := b.AddPod(old); err != nil {
+		t.Fatal(err)
+	}
+	server.FailNext(1, http.StatusBadRequest)
+	if err := b.UpdatePod(old, testPod(podUID, "Running", "192.168.1.1")); err != nil {
+		t.Fatal(err)
+	}
+	if r := lastRequest(t, server); r.Method != http.MethodPut {
+		t.Errorf("fallback sent %s %s, expected a PUT", r.Method, r.Path)
+	}
+	if value, _ := storedLeaf(t, server, podsContainer, podUID, "phase"); value != `"Running"` {
+		t.Errorf("stored phase %s, expected \"Running\"", value)
+	}
+}
+
+func batchPod(uid string) *v1.Pod {
+	return testPod(uid, "Running", "192.168.1.1")
+}
+
+// Added objects are buffered until the flush, then written with one
+// yang-patch per container.
+func TestBatchFlush(t *testing.T) {
+	options := directOptions()
+	options.BatchWindow = time.Hour
+	server, b := newTestBackend(t, options)
+
+	uids := []string{
+		"11111111-1111-1111-1111-000000000001",
+		"11111111-1111-1111-1111-000000000002",
+		"11111111-1111-1111-1111-000000000003",
+	}
+	for _, uid := range uids {
+		if err := b.AddPod(batchPod(uid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.AddService(testService("10.96.0.10")); err != nil {
+		t.Fatal(err)
+	}
+	// updating a buffered object replaces it, deleting one drops it
+	if err := b.UpdatePod(batchPod(uids[0]), testPod(uids[0], "Failed", "192.168.1.1")); err != nil {
+		t.Fatal(err)
+	}
+	// the pod was never written, so ODL answers the DELETE with a 404
+	b.DeletePod(batchPod(uids[2]))
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Method != http.MethodDelete {
+		t.Fatalf("sent %+v before the flush, expected the DELETE alone", requests)
+	}
+
+	b.Flush()
+	requests := server.Requests()[1:]
+	if len(requests) != 2 {
+		t.Fatalf("flush sent %d requests, expected one per container", len(requests))
+	}
+	for i, c := range []container{podsContainer, servicesContainer} {
+		if requests[i].Method != http.MethodPatch || requests[i].Path != c.url || requests[i].ContentType != yangPatchContentType {
+			t.Errorf("request %d is %s %s as %s, expected a yang-patch of %s",
+				i, requests[i].Method, requests[i].Path, requests[i].ContentType, c.url)
+		}
+	}
+	if keys := stored(server, podsContainer); len(keys) != 2 || keys[0] != uids[0] || keys[1] != uids[1] {
+		t.Errorf("stored pods %v, expected %v", keys, uids[:2])
+	}
+	if value, _ := storedLeaf(t, server, podsContainer, uids[0], "phase"); value != `"Failed"` {
+		t.Errorf("stored phase %s, expected the update", value)
+	}
+	if keys := stored(server, servicesContainer); len(keys) != 1 {
+		t.Errorf("stored services %v, expected one", keys)
+	}
+
+	b.Flush()
+	if requests := server.Requests(); len(requests) != 3 {
+		t.Errorf("an empty flush sent %d requests", len(requests)-3)
+	}
+}
+
+// A container reaching the batch size is sent right away.
+func TestBatchSize(t *testing.T) {
+	options := directOptions()
+	options.BatchWindow = time.Hour
+	options.BatchSize = 2
+	server, b := newTestBackend(t, options)
+
+	b.AddPod(batchPod("11111111-1111-1111-1111-000000000001"))
+	if requests := server.Requests(); len(requests) != 0 {
+		t.Fatalf("sent %d requests below the batch size", len(requests))
+	}
+	b.AddPod(batchPod("11111111-1111-1111-1111-000000000002"))
+	if keys := stored(server, podsContainer); len(keys) != 2 {
+		t.Errorf("stored %v once the batch was full, expected both pods", keys)
+	}
+}
+
+// ODL builds without yang-patch support get the batch one entry at a time.
+func TestBatchFallsBackToPut(t *testing.T) {
+	options := directOptions()
+	options.BatchWindow = time.Hour
+	server, b := newTestBackend(t, options)
+
+	b.AddPod(batchPod("11111111-1111-1111-1111-000000000001"))
+	b.AddPod(batchPod("11111111-1111-1111-1111-000000000002"))
+	server.FailNext(1, http.StatusMethodNotAllowed)
+	b.Flush()
+
+	requests := server.Requests()
+	if len(requests) != 3 || requests[1].Method != http.MethodPut || requests[2].Method != http.MethodPut {
+		t.Errorf("sent %+v, expected the rejected PATCH and two PUTs", requests)
+	}
+	if keys := stored(server, podsContainer); len(keys) != 2 {
+		t.Errorf("stored %v, expected both pods", keys)
+	}
+}
+
+// A delete arriving while the batch holding the object is being sent goes
+// out after it, instead of being overtaken and undone by the batch.
+func TestBatchDeleteDuringFlush(t *testing.T) {
+	options := directOptions()
+	options.BatchWindow = time.Hour
+	server, b := newTestBackend(t, options)
+
+	pod := batchPod(podUID)
+	if err := b.AddPod(pod); err != nil {
+		t.Fatal(err)
+	}
+	server.SetLatency(100 * time.Millisecond)
+	flushed := make(chan struct{})
+	go func() {
+		b.Flush()
+		close(flushed)
+	}()
+	eventually(t, "the batch to be sent", func() bool { return len(server.Requests()) == 1 })
+	server.SetLatency(0)
+
+	if err := b.DeletePod(pod); err != nil {
+		t.Errorf("DeletePod() = %v", err)
+	}
+	<-flushed
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Method != http.MethodPatch || requests[1].Method != http.MethodDelete {
+		t.Errorf("sent %+v, expected the batch then the DELETE", requests)
+	}
+	if keys := stored(server, podsContainer); len(keys) != 0 {
+		t.Errorf("stored %v, expected the deleted pod to be gone", keys)
+	}
+}
+
+// While ODL is down the requests are queued, and they are replayed in order
+// followed by a resync once it answers again.
+func TestBreakerAgainstServer(t *testing.T) {
+	var lock sync.Mutex
+	resyncs := 0
+	options := directOptions()
+	options.BreakerThreshold = 2
+	options.BreakerCooldown = 10 * time.Millisecond
+	options.Resync = func() {
+		lock.Lock()
+		defer lock.Unlock()
+		resyncs++
+	}
+	server, b := newTestBackend(t, options)
+
+	server.FailPath("/restconf/config/", http.StatusServiceUnavailable)
+	for _, uid := range []string{"11111111-1111-1111-1111-000000000001", "11111111-1111-1111-1111-000000000002"} {
+		if err := b.AddPod(batchPod(uid)); !isQueued(err) {
+			t.Fatalf("AddPod() = %v, expected the request to be queued", err)
+		}
+	}
+	eventually(t, "the circuit to open", func() bool { return isOpen(b.breaker) })
+
+	if err := b.AddService(testService("10.96.0.10")); err == nil || err.Error() != (queuedError{errCircuitOpen}).Error() {
+		t.Fatalf("AddService() = %v, expected %v", err, queuedError{errCircuitOpen})
+	}
+	if err := b.DeletePod(batchPod("11111111-1111-1111-1111-000000000002")); !isQueued(err) {
+		t.Fatalf("DeletePod() = %v, expected the request to be queued", err)
+	}
+	if keys := stored(server, podsContainer); len(keys) != 0 {
+		t.Fatalf("stored %v while ODL was down", keys)
+	}
+
+	server.ClearFailures()
+	eventually(t, "the replay and the resync", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return resyncs == 1
+	})
+	if keys := stored(server, podsContainer); len(keys) != 1 || keys[0] != "11111111-1111-1111-1111-000000000001" {
+		t.Errorf("stored pods %v, expected the deleted one to be gone", keys)
+	}
+	if keys := stored(server, servicesContainer); len(keys) != 1 {
+		t.Errorf("stored services %v, expected the queued one", keys)
+	}
+	if err := b.AddNode(testNode("192.168.1.1")); err != nil {
+		t.Errorf("AddNode() after recovery = %v", err)
+	}
+}
+
+// The backend authenticates with the configured credentials and picks up
+// new ones without being rebuilt.
+func TestCredentials(t *testing.T) {
+	server, b := newTestBackend(t, directOptions())
+	server.SetCredentials("admin", "secret")
+	if err := b.AddPod(batchPod(podUID)); err == nil {
+		t.Fatal("AddPod() with wrong credentials succeeded")
+	}
+	b.SetCredentials(Credentials{Username: "admin", Password: "secret"})
+	if err := b.AddPod(batchPod(podUID)); err != nil {
+		t.Fatalf("AddPod() = %v", err)
+	}
+}
+
+// The cluster is registered under the backend's cluster id.
+func TestAddCluster(t *testing.T) {
+	server, b := newTestBackend(t, directOptions())
+	if err := b.AddCluster(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := server.Keys("k8s-cluster:k8s-clusters-info", "k8s-clusters"); len(keys) != 1 || keys[0] != b.clusterId {
+		t.Errorf("stored clusters %v, expected %s", keys, b.clusterId)
+	}
+	clusters, err := b.Clusters()
+	if err != nil || len(clusters) != 1 || clusters[0].ClusterID != b.clusterId {
+		t.Errorf("Clusters() = %+v, %v", clusters, err)
+	}
+}
@@ -9,11 +9,19 @@
 package odl
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net"
+	"sort"
+	"strconv"
+	"time"
 
 	"k8s.io/api/core/v1"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl/model"
 )
 
 const (
@@ -24,77 +32,170 @@ const (
 	ClustersUrl = "/restconf/config/k8s-cluster:k8s-clusters-info/"
 )
 
+// container describes a RESTCONF container holding one list of objects keyed
+// by uid, so that objects can be written either one by one or in bulk.
+type container struct {
+	// url of the container itself, used for bulk requests
+	url string
+	// listUrl is the prefix of a single list entry's url
+	listUrl string
+	// list is the name of the list inside the container
+	list string
+	// member is the name of the list as it appears in request bodies
+	member string
+	// key is the name of the uid leaf as it appears in request bodies
+	key string
+}
+
+var (
+	podsContainer = container{
+		url:     "/restconf/config/pod:coe",
+		listUrl: PodsUrl,
+		list:    "pods",
+		member:  "pod:pods",
+		key:     "uid",
+	}
+	nodesContainer = container{
+		url:     "/restconf/config/k8s-node:k8s-nodes-info",
+		listUrl: NodesUrl,
+		list:    "k8s-nodes",
+		member:  "k8s-node:k8s-nodes",
+		key:     "uid",
+	}
+	servicesContainer = container{
+		url:     "/restconf/config/service:service-information",
+		listUrl: ServicesUrl,
+		list:    "services",
+		member:  "service:services",
+		key:     "uid",
+	}
+	endpointsContainer = container{
+		url:     "/restconf/config/service:endpoints-info",
+		listUrl: EndPointsUrl,
+		list:    "endpoints",
+		member:  "service:endpoints",
+		key:     "uid",
+	}
+)
+
 // Setting the Node attributes based on K8s API server doc
 // https://kubernetes.io/docs/concepts/architecture/nodes/#addresses
-func createNodeStructure(node *v1.Node, clusterID string) []byte {
-	odlNodes := make([]Node, 1)
-	odlNodes[0] = Node{
-		UID:       node.GetUID(),
+func createOdlNode(node *v1.Node, clusterID string) *model.K8sNode {
+	odlNode := &model.K8sNode{
+		UID:       string(node.GetUID()),
 		PodCIDR:   node.Spec.PodCIDR,
+		PodCIDRs:  node.Spec.PodCIDRs,
 		ClusterID: clusterID,
+		Ready:     backends.IsNodeReady(node),
+	}
+	if len(odlNode.PodCIDRs) == 0 && node.Spec.PodCIDR != "" {
+		odlNode.PodCIDRs = []string{node.Spec.PodCIDR}
 	}
 
+	// The first address of each type fills the legacy single-address leaves,
+	// while every address is kept in the address list.
 	for _, address := range node.Status.Addresses {
 		switch address.Type {
 		case v1.NodeHostName:
 			{
-				odlNodes[0].HostName = address.Address
+				if odlNode.HostName == "" {
+					odlNode.HostName = address.Address
+				}
 			}
 		case v1.NodeInternalIP:
 			{
-				odlNodes[0].InternalIPAddress = net.ParseIP(address.Address)
+				if odlNode.InternalIPAddress == "" {
+					odlNode.InternalIPAddress = formatIP(address.Address)
+				}
 			}
 		case v1.NodeExternalIP:
 			{
-				odlNodes[0].ExternalIPAddress = net.ParseIP(address.Address)
+				if odlNode.ExternalIPAddress == "" {
+					odlNode.ExternalIPAddress = formatIP(address.Address)
+				}
 			}
+		case v1.NodeInternalDNS, v1.NodeExternalDNS:
 		default:
 			{
 				log.Println("Unknown address type: ", address.Type)
+				continue
 			}
 		}
+		odlNode.NodeAddresses = append(odlNode.NodeAddresses, model.K8sNodeAddress{
+			Type:    string(address.Type),
+			Address: address.Address,
+		})
 	}
 
-	js, err := json.Marshal(odlNodes)
-	if err != nil {
-		log.Println("Error while formating k8s node object", err)
+	for _, condition := range node.Status.Conditions {
+		odlNode.Conditions = append(odlNode.Conditions, model.K8sNodeCondition{
+			Type:   string(condition.Type),
+			Status: string(condition.Status),
+			Reason: condition.Reason,
+		})
 	}
-	jsStr := `{"k8s-node:k8s-nodes":` + string(js) + "}"
-	return []byte(jsStr)
-}
 
-func createPodStructure(pod *v1.Pod, clusterID string) []byte {
-	interfaces := make([]Interface, 1)
+	labels := node.GetLabels()
+	for _, key := range sortedKeys(labels) {
+		odlNode.Labels = append(odlNode.Labels, model.K8sNodeLabel{
+			Key:   key,
+			Value: labels[key],
+		})
+	}
+
+	for _, taint := range node.Spec.Taints {
+		odlNode.Taints = append(odlNode.Taints, model.K8sNodeTaint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: string(taint.Effect),
+		})
+	}
 
-	interfaces[0] = Interface{
-		UID:         pod.GetUID(),
-		NetworkID:   "00000000-0000-0000-0000-000000000000",
-		NetworkType: "VXLAN",
-		IPAddress:   net.ParseIP(pod.Status.PodIP),
+	if endpoint, ok := node.GetAnnotations()[backends.TunnelEndpointAnnotation]; ok {
+		odlNode.TunnelEndpointIPAddress = formatIP(endpoint)
+		if odlNode.TunnelEndpointIPAddress == "" {
+			log.Printf("Invalid %s annotation on node %s: %q\n", backends.TunnelEndpointAnnotation, node.GetName(), endpoint)
+		}
 	}
-	pods := make([]Pod, 1)
-	pods[0] = Pod{
-		UID:           pod.GetUID(),
+
+	return odlNode
+}
+
+func createOdlPod(pod *v1.Pod, clusterID string) *model.Pod {
+	odlPod := &model.Pod{
+		UID:           string(pod.GetUID()),
 		ClusterID:     clusterID,
 		Name:          pod.GetName(),
-		HostIPAddress: pod.Status.HostIP,
+		HostIPAddress: formatIP(pod.Status.HostIP),
 		NetworkNS:     pod.Namespace,
-		Interfaces:    interfaces,
+		NodeName:      pod.Spec.NodeName,
+		Phase:         string(pod.Status.Phase),
+		Ready:         backends.IsPodReady(pod),
+		Interface: []model.PodInterface{
+			{
+				UID:         string(pod.GetUID()),
+				NetworkID:   "00000000-0000-0000-0000-000000000000",
+				NetworkType: "VXLAN",
+				IPAddress:   formatIP(pod.Status.PodIP),
+			},
+		},
 	}
-	coe := Coe{
-		Pods: pods,
+
+	labels := pod.GetLabels()
+	for _, key := range sortedKeys(labels) {
+		odlPod.Labels = append(odlPod.Labels, model.PodLabel{Key: key, Value: labels[key]})
 	}
-	js, err := json.Marshal(coe)
-	if err != nil {
-		log.Println("Error while formating pod object", err)
+	annotations := pod.GetAnnotations()
+	for _, key := range sortedKeys(annotations) {
+		odlPod.Annotations = append(odlPod.Annotations, model.PodAnnotation{Key: key, Value: annotations[key]})
 	}
-	return js
+	return odlPod
 }
 
-func createServiceStructure(service *v1.Service, clusterID string) []byte {
-	srvPorts := make([]ServicePorts, len(service.Spec.Ports))
+func createOdlService(service *v1.Service, clusterID string) *model.Service {
+	srvPorts := make([]model.ServicePort, len(service.Spec.Ports))
 	for i := 0; i < len(service.Spec.Ports); i++ {
-		srvPorts[i] = ServicePorts{
+		srvPorts[i] = model.ServicePort{
 			Name:     service.Spec.Ports[i].Name,
 			NodePort: service.Spec.Ports[i].NodePort,
 			Port:     service.Spec.Ports[i].Port,
@@ -102,72 +203,174 @@ func createServiceStructure(service *v1.Service, clusterID string) []byte {
 		srvPorts[i].TargetPort = service.Spec.Ports[i].TargetPort.String()
 	}
 
-	exIPs := make([]net.IP, len(service.Spec.ExternalIPs))
+	var exIPs []string
 	for i := 0; i < len(service.Spec.ExternalIPs); i++ {
-		ip := net.ParseIP(service.Spec.ExternalIPs[i])
-		if ip != nil {
-			exIPs[i] = ip
+		if ip := formatIP(service.Spec.ExternalIPs[i]); ip != "" {
+			exIPs = append(exIPs, ip)
 		}
 	}
 
-	ingressIPs := make([]net.IP, len(service.Status.LoadBalancer.Ingress))
+	var ingressIPs []string
 	for i := 0; i < len(service.Status.LoadBalancer.Ingress); i++ {
-		ip := net.ParseIP(service.Status.LoadBalancer.Ingress[i].IP)
-		if ip != nil {
-			ingressIPs[i] = ip
+		if ip := formatIP(service.Status.LoadBalancer.Ingress[i].IP); ip != "" {
+			ingressIPs = append(ingressIPs, ip)
 		}
 	}
 
-	services := make([]Service, 1)
-	services[0] = Service{
-		UID:                   service.GetUID(),
-		ClusterID:             clusterID,
-		Name:                  service.GetName(),
-		ClusterIPAddress:      net.ParseIP(service.Spec.ClusterIP),
-		ExternalIPAddress:     exIPs,
-		IngressIPAddress:      ingressIPs,
-		NetworkNS:             service.Namespace,
-		LoadBalancerIPAddress: net.ParseIP(service.Spec.LoadBalancerIP),
-		ServicePorts:          srvPorts,
+	return &model.Service{
+		UID:               string(service.GetUID()),
+		ClusterID:         clusterID,
+		Name:              service.GetName(),
+		ClusterIPAddress:  formatIP(service.Spec.ClusterIP),
+		ExternalIPAddress: exIPs,
+		IngressIPAddress:  ingressIPs,
+		NetworkNS:         service.Namespace,
+		LoadBalancerIP:    formatIP(service.Spec.LoadBalancerIP),
+		ServicePorts:      srvPorts,
 	}
-	js, err := json.Marshal(services)
-	if err != nil {
-		log.Println("Error while formating service object", err)
-	}
-	jsStr := `{"service:services":` + string(js) + "}"
-	return []byte(jsStr)
 }
 
-func createEndpointStructure(endpoint *v1.Endpoints, clusterID string) []byte {
-	endPoints := make([]EndPoints, 1)
-	endPoints[0] = EndPoints{
-		UID:       endpoint.GetUID(),
+func createOdlEndpoints(endpoint *v1.Endpoints, clusterID string) *model.Endpoint {
+	endPoints := &model.Endpoint{
+		UID:       string(endpoint.GetUID()),
 		Name:      endpoint.GetName(),
 		NetworkNS: endpoint.GetNamespace(),
 		ClusterID: clusterID,
 	}
 	if len(endpoint.Subsets) > 0 {
-		endPointsAddresses := make([]EndPointsAddresses, len(endpoint.Subsets[0].Addresses))
+		endPointsAddresses := make([]model.EndpointAddress, len(endpoint.Subsets[0].Addresses))
 		for i := 0; i < len(endpoint.Subsets[0].Addresses); i++ {
 			endPointsAddresses[i].HostName = endpoint.Subsets[0].Addresses[i].Hostname
-			ip := net.ParseIP(endpoint.Subsets[0].Addresses[i].IP)
-			if ip != nil {
-				endPointsAddresses[i].IPAddress = ip
+			endPointsAddresses[i].IPAddress = formatIP(endpoint.Subsets[0].Addresses[i].IP)
+			if nodeName := endpoint.Subsets[0].Addresses[i].NodeName; nodeName != nil {
+				endPointsAddresses[i].NodeName = *nodeName
 			}
-			endPointsAddresses[i].NodeName = endpoint.Subsets[0].Addresses[i].NodeName
 		}
-		endPntPorts := make([]EndPointsPorts, len(endpoint.Subsets[0].Ports))
+		endPntPorts := make([]model.EndpointPort, len(endpoint.Subsets[0].Ports))
 		for i := 0; i < len(endpoint.Subsets[0].Ports); i++ {
 			endPntPorts[i].Name = endpoint.Subsets[0].Ports[i].Name
 			endPntPorts[i].Port = endpoint.Subsets[0].Ports[i].Port
 		}
-		endPoints[0].EndPointAddresses = endPointsAddresses
-		endPoints[0].EndPointPorts = endPntPorts
+		endPoints.EndpointAddresses = endPointsAddresses
+		endPoints.EndpointPorts = endPntPorts
 	}
-	js, err := json.Marshal(endPoints)
+	return endPoints
+}
+
+// formatIP returns the canonical form of the IP address, or "" when it is not
+// one, e.g. for the "None" cluster IP of headless services.
+func formatIP(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// createListStructure wraps a single list entry the way RESTCONF expects it
+// when the entry is written on its own url.
+func createListStructure(c container, entry interface{}) []byte {
+	js, err := json.Marshal(map[string]interface{}{
+		c.member: []interface{}{entry},
+	})
 	if err != nil {
-		log.Println("Error while formating service object", err)
+		log.Printf("Error while formating %s object %v\n", c.list, err)
+	}
+	return js
+}
+
+// createPatch computes the yang-patch turning oldEntry into newEntry by
+// merging the leaves that changed and removing the ones that disappeared.
+// changed is false when both entries are identical, in which case nothing
+// needs to be sent. ok is false when the change touches a nested list or
+// container, which cannot be merged leaf by leaf and has to be written
+// with a PUT of the whole entry.
+func createPatch(c container, uid string, oldEntry, newEntry interface{}) (patch YangPatch, changed bool, ok bool) {
+	oldLeaves, err := toLeaves(oldEntry)
+	if err != nil {
+		return patch, true, false
+	}
+	newLeaves, err := toLeaves(newEntry)
+	if err != nil {
+		return patch, true, false
+	}
+
+	target := "/" + c.list + "=" + uid
+	merged := map[string]json.RawMessage{c.key: newLeaves[c.key]}
+	var removed []string
+	for name, value := range newLeaves {
+		if oldValue, found := oldLeaves[name]; found && bytes.Equal(oldValue, value) {
+			continue
+		}
+		if isNested(value) || isNested(oldLeaves[name]) {
+			return patch, true, false
+		}
+		merged[name] = value
+	}
+	for name, value := range oldLeaves {
+		if _, found := newLeaves[name]; found {
+			continue
+		}
+		if isNested(value) {
+			return patch, true, false
+		}
+		removed = append(removed, name)
+	}
+	if len(merged) == 1 && len(removed) == 0 {
+		return patch, false, true
+	}
+	sort.Strings(removed)
+
+	value, err := json.Marshal(map[string]interface{}{
+		c.member: []interface{}{merged},
+	})
+	if err != nil {
+		return patch, true, false
+	}
+	patch.Patch.PatchID = fmt.Sprintf("coe-%s-%s-%d", c.list, uid, time.Now().UnixNano())
+	if len(merged) > 1 {
+		patch.Patch.Edits = append(patch.Patch.Edits, YangPatchEdit{
+			EditID:    "1",
+			Operation: "merge",
+			Target:    target,
+			Value:     value,
+		})
+	}
+	for _, name := range removed {
+		patch.Patch.Edits = append(patch.Patch.Edits, YangPatchEdit{
+			EditID:    strconv.Itoa(len(patch.Patch.Edits) + 1),
+			Operation: "remove",
+			Target:    target + "/" + name,
+		})
+	}
+	return patch, true, true
+}
+
+func toLeaves(entry interface{}) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	leaves := make(map[string]json.RawMessage)
+	err = json.Unmarshal(js, &leaves)
+	return leaves, err
+}
+
+// isNested reports whether the JSON value is a list or a container rather
+// than a leaf. JSON null is how empty leaf-lists are sent, so it counts as
+// nested too.
+func isNested(value json.RawMessage) bool {
+	value = bytes.TrimSpace(value)
+	return len(value) > 0 && (value[0] == '[' || value[0] == '{' || bytes.Equal(value, []byte("null")))
+}
+
+// sortedKeys returns the keys of a label or annotation map in order, so that
+// identical maps always produce identical payloads.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
 	}
-	jsStr := `{"service:endpoints":` + string(js) + "}"
-	return []byte(jsStr)
+	sort.Strings(keys)
+	return keys
 }
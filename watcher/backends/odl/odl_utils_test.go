@@ -0,0 +1,90 @@
+package odl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func readyPod(ready bool) *v1.Pod {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "pod-1", Name: "web", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			PodIP:      "10.11.1.2",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}},
+		},
+	}
+}
+
+func readyNode(ready bool) *v1.Node {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{UID: "node-1", Name: "node-1"},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}},
+	}
+}
+
+// mergedLeaves returns the leaves of the merge edit of the patch, failing
+// when the patch removes anything
+func mergedLeaves(t *testing.T, c container, patch YangPatch) map[string]json.RawMessage {
+	var merge *YangPatchEdit
+	for i, edit := range patch.Patch.Edits {
+		switch edit.Operation {
+		case "merge":
+			merge = &patch.Patch.Edits[i]
+		default:
+			t.Errorf("unexpected %s of %s", edit.Operation, edit.Target)
+		}
+	}
+	if merge == nil {
+		t.Fatalf("no merge edit in %+v", patch)
+	}
+	var value map[string][]map[string]json.RawMessage
+	if err := json.Unmarshal(merge.Value, &value); err != nil {
+		t.Fatal(err)
+	}
+	if len(value[c.member]) != 1 {
+		t.Fatalf("merge value %s does not hold one %s entry", merge.Value, c.member)
+	}
+	return value[c.member][0]
+}
+
+func TestCreatePatchUnready(t *testing.T) {
+	old, new := createOdlPod(readyPod(true), "cluster"), createOdlPod(readyPod(false), "cluster")
+	patch, changed, ok := createPatch(podsContainer, "pod-1", old, new)
+	if !changed || !ok {
+		t.Fatalf("createPatch() changed %v ok %v, expected a patch", changed, ok)
+	}
+	leaves := mergedLeaves(t, podsContainer, patch)
+	if string(leaves["ready"]) != "false" {
+		t.Errorf("ready merged as %s, expected false", leaves["ready"])
+	}
+}
+
+// The node conditions change with its readiness, so that the node is
+// written whole, ready included
+func TestUnreadyEntries(t *testing.T) {
+	for name, entry := range map[string]interface{}{
+		"pod":  createOdlPod(readyPod(false), "cluster"),
+		"node": createOdlNode(readyNode(false), "cluster"),
+	} {
+		leaves, err := toLeaves(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(leaves["ready"]) != "false" {
+			t.Errorf("%s ready serialized as %q, expected false", name, leaves["ready"])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ovsdb
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/journal"
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "ovsdb",
+	Short: "ovsdb watcher",
+	Long:  "Watches Kubernetes and programs the local Open vSwitch through OVSDB, without OpenDaylight",
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Println("Run OVSDB watcher")
+		commands.RequireClientSet()
+
+		commands.BindFlags(cmd, flagKeys)
+		backend, err := newBackend()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		nodes, err := commands.Config.ClientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			log.Println("unable to list the nodes, keeping the existing tunnels:", err)
+		} else {
+			backend.PruneTunnels(nodes.Items)
+		}
+
+		backends.Watch(commands.Config.ClientSet, journal.Use(backend))
+		backend.Flush()
+	},
+}
+
+// newBackend creates the backend of the ovsdb section.
+func newBackend() (*Backend, error) {
+	var config Config
+	if err := commands.LoadSection("ovsdb", &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return New(config)
+}
+
+func init() {
+	defaults := DefaultConfig()
+	Cmd.Flags().String("endpoint", defaults.Endpoint, "OVSDB server, unix:<socket> or tcp:<host>:<port>")
+	Cmd.Flags().String("bridge", defaults.Bridge, "OVS bridge the pods are plugged into")
+	Cmd.Flags().String("node-name", defaults.NodeName, "Kubernetes name of the local node")
+	Cmd.Flags().String("cluster-id", defaults.ClusterID, "Cluster id recorded by odlovs-cni on the pod ports")
+	Cmd.Flags().String("tunnel-type", defaults.TunnelType, "Type of the tunnels to the other nodes: vxlan, geneve or gre")
+	commands.RootCmd.AddCommand(Cmd)
+	journal.RegisterBackend("ovsdb", func() (backends.Coe, error) {
+		backend, err := newBackend()
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	})
+}
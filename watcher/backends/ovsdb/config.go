@@ -0,0 +1,74 @@
+package ovsdb
+
+import (
+	"fmt"
+	"os"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Config is the ovsdb section of the configuration file.
+type Config struct {
+	// Endpoint is the OVSDB server, unix:<socket> or tcp:<host>:<port>.
+	Endpoint string `mapstructure:"endpoint"`
+	// Bridge is the integration bridge odlovs-cni plugs the pods into.
+	Bridge string `mapstructure:"bridge"`
+	// NodeName is the Kubernetes name of the local node.
+	NodeName string `mapstructure:"node-name"`
+	// ClusterID is the cluster-id odlovs-cni records on the pod ports, as
+	// configured in its clusterId.
+	ClusterID string `mapstructure:"cluster-id"`
+	// TunnelType is the OVS interface type of the tunnels, vxlan, geneve
+	// or gre.
+	TunnelType string `mapstructure:"tunnel-type"`
+}
+
+// flagKeys maps the command line flags to the configuration keys they
+// override.
+var flagKeys = map[string]string{
+	"endpoint":    "ovsdb.endpoint",
+	"bridge":      "ovsdb.bridge",
+	"node-name":   "ovsdb.node-name",
+	"cluster-id":  "ovsdb.cluster-id",
+	"tunnel-type": "ovsdb.tunnel-type",
+}
+
+func DefaultConfig() Config {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		nodeName, _ = os.Hostname()
+	}
+	return Config{
+		Endpoint:   "unix:/var/run/openvswitch/db.sock",
+		Bridge:     "ovsbrk8s",
+		NodeName:   nodeName,
+		ClusterID:  "00000000-0000-0000-0000-000000000001",
+		TunnelType: "vxlan",
+	}
+}
+
+func (c *Config) Validate() error {
+	if _, _, err := splitEndpoint(c.Endpoint); err != nil {
+		return fmt.Errorf("endpoint: %v", err)
+	}
+	if c.Bridge == "" {
+		return fmt.Errorf("bridge: required")
+	}
+	if c.NodeName == "" {
+		return fmt.Errorf("node-name: required")
+	}
+	if c.ClusterID == "" {
+		return fmt.Errorf("cluster-id: required")
+	}
+	switch c.TunnelType {
+	case "vxlan", "geneve", "gre":
+	default:
+		return fmt.Errorf("tunnel-type: %q is not one of vxlan, geneve or gre", c.TunnelType)
+	}
+	return nil
+}
+
+func init() {
+	defaults := DefaultConfig()
+	commands.RegisterSection("ovsdb", &defaults)
+}
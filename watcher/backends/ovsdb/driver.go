@@ -0,0 +1,413 @@
+package ovsdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/socketplane/libovsdb"
+)
+
+const (
+	database = "Open_vSwitch"
+
+	// ovsdb operations
+	insertOpr = "insert"
+	deleteOpr = "delete"
+	updateOpr = "update"
+	mutateOpr = "mutate"
+)
+
+// driver programs the local OVS through OVSDB, the way odlovs-cni's
+// OvsDriver does, but returns its errors rather than exiting so that the
+// watcher keeps running.
+type driver struct {
+	// OVS client
+	ovsClient *libovsdb.OvsdbClient
+
+	// Name of the OVS bridge
+	bridgeName string
+
+	// OVSDB cache
+	ovsdbCache map[string]map[string]libovsdb.Row
+
+	// read/write lock for accessing the cache
+	lock sync.RWMutex
+}
+
+// connect opens the OVSDB connection at endpoint, "unix:<socket>" or
+// "tcp:<host>:<port>", and fills the cache.
+func connect(endpoint, bridgeName string) (*driver, error) {
+	protocol, target, err := splitEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ovs, err := libovsdb.ConnectUsingProtocol(protocol, target)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to ovsdb at %s: %v", endpoint, err)
+	}
+
+	self := &driver{
+		ovsClient:  ovs,
+		bridgeName: bridgeName,
+		ovsdbCache: make(map[string]map[string]libovsdb.Row),
+	}
+	ovs.Register(self)
+	initial, err := ovs.MonitorAll(database, "")
+	if err != nil {
+		self.disconnect()
+		return nil, fmt.Errorf("unable to monitor ovsdb: %v", err)
+	}
+	self.populateCache(*initial)
+
+	if !self.isBridgePresent() {
+		self.disconnect()
+		return nil, fmt.Errorf("bridge %s does not exist", bridgeName)
+	}
+	return self, nil
+}
+
+func splitEndpoint(endpoint string) (protocol, target string, err error) {
+	parts := strings.SplitN(endpoint, ":", 2)
+	if len(parts) != 2 || parts[1] == "" || (parts[0] != "unix" && parts[0] != "tcp") {
+		return "", "", fmt.Errorf("%q is neither unix:<socket> nor tcp:<host>:<port>", endpoint)
+	}
+	return parts[0], parts[1], nil
+}
+
+// disconnect closes the OVSDB connection.
+func (self *driver) disconnect() {
+	self.ovsClient.Unregister(self)
+	self.ovsClient.Disconnect()
+}
+
+// Populate local cache of ovs state
+func (self *driver) populateCache(updates libovsdb.TableUpdates) {
+	// lock the cache for write
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for table, tableUpdate := range updates.Updates {
+		if _, ok := self.ovsdbCache[table]; !ok {
+			self.ovsdbCache[table] = make(map[string]libovsdb.Row)
+		}
+
+		for uuid, row := range tableUpdate.Rows {
+			empty := libovsdb.Row{}
+			if !reflect.DeepEqual(row.New, empty) {
+				self.ovsdbCache[table][uuid] = row.New
+			} else {
+				delete(self.ovsdbCache[table], uuid)
+			}
+		}
+	}
+}
+
+// Wrapper for ovsDB transaction
+func (self *driver) transact(ops ...libovsdb.Operation) error {
+	reply, err := self.ovsClient.Transact(database, ops...)
+	if err != nil {
+		return err
+	}
+
+	if len(reply) < len(ops) {
+		return errors.New("OVS transaction failed. Unexpected number of replies")
+	}
+
+	// Parse reply and look for errors
+	for _, o := range reply {
+		if o.Error != "" {
+			return errors.New("OVS Transaction failed err " + o.Error + " Details: " + o.Details)
+		}
+	}
+	return nil
+}
+
+// isBridgePresent checks the bridge exists.
+func (self *driver) isBridgePresent() bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for _, row := range self.ovsdbCache["Bridge"] {
+		if name, ok := row.Fields["name"]; ok && name == self.bridgeName {
+			return true
+		}
+	}
+	return false
+}
+
+// findRows returns the rows of table holding an external id under key,
+// by uuid.
+func (self *driver) findRows(table, key string) map[string]libovsdb.Row {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	rows := make(map[string]libovsdb.Row)
+	for uuid, row := range self.ovsdbCache[table] {
+		if _, ok := goMap(row.Fields["external_ids"])[key]; ok {
+			rows[uuid] = row
+		}
+	}
+	return rows
+}
+
+// findByName returns the uuid and the row of table named name.
+func (self *driver) findByName(table, name string) (string, libovsdb.Row, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for uuid, row := range self.ovsdbCache[table] {
+		if row.Fields["name"] == name {
+			return uuid, row, true
+		}
+	}
+	return "", libovsdb.Row{}, false
+}
+
+// updateOp sets the columns of the row of table identified by uuid.
+func updateOp(table, uuid string, columns map[string]interface{}) libovsdb.Operation {
+	return libovsdb.Operation{
+		Op:    updateOpr,
+		Table: table,
+		Row:   columns,
+		Where: []interface{}{libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: uuid})},
+	}
+}
+
+// createPort adds a port and its interface to the bridge.
+func (self *driver) createPort(name, intfType string, options, extIDs map[string]string) error {
+	portUuidStr := "coePort"
+	intfUuidStr := "coeIntf"
+
+	intf := map[string]interface{}{
+		"name": name,
+		"type": intfType,
+	}
+	intf["options"], _ = libovsdb.NewOvsMap(options)
+	intf["external_ids"], _ = libovsdb.NewOvsMap(extIDs)
+
+	port := map[string]interface{}{
+		"name": name,
+	}
+	port["interfaces"], _ = libovsdb.NewOvsSet([]libovsdb.UUID{{GoUUID: intfUuidStr}})
+	port["external_ids"], _ = libovsdb.NewOvsMap(extIDs)
+
+	intfOp := libovsdb.Operation{
+		Op:       insertOpr,
+		Table:    "Interface",
+		Row:      intf,
+		UUIDName: intfUuidStr,
+	}
+	portOp := libovsdb.Operation{
+		Op:       insertOpr,
+		Table:    "Port",
+		Row:      port,
+		UUIDName: portUuidStr,
+	}
+
+	// mutate the Ports column in the Bridge table
+	mutateSet, _ := libovsdb.NewOvsSet([]libovsdb.UUID{{GoUUID: portUuidStr}})
+	mutation := libovsdb.NewMutation("ports", insertOpr, mutateSet)
+	condition := libovsdb.NewCondition("name", "==", self.bridgeName)
+	mutateOp := libovsdb.Operation{
+		Op:        mutateOpr,
+		Table:     "Bridge",
+		Mutations: []interface{}{mutation},
+		Where:     []interface{}{condition},
+	}
+
+	return self.transact(intfOp, portOp, mutateOp)
+}
+
+// deletePort removes the port, its interface and its QoS, if any, from the
+// bridge.
+func (self *driver) deletePort(uuid string, port libovsdb.Row) error {
+	var operations []libovsdb.Operation
+	for _, intf := range uuids(port.Fields["interfaces"]) {
+		operations = append(operations, libovsdb.Operation{
+			Op:    deleteOpr,
+			Table: "Interface",
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: intf})},
+		})
+	}
+	operations = append(operations, libovsdb.Operation{
+		Op:    deleteOpr,
+		Table: "Port",
+		Where: []interface{}{libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: uuid})},
+	})
+	for _, qos := range uuids(port.Fields["qos"]) {
+		operations = append(operations, libovsdb.Operation{
+			Op:    deleteOpr,
+			Table: "QoS",
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: qos})},
+		})
+	}
+
+	mutateSet, _ := libovsdb.NewOvsSet([]libovsdb.UUID{{GoUUID: uuid}})
+	mutation := libovsdb.NewMutation("ports", deleteOpr, mutateSet)
+	condition := libovsdb.NewCondition("name", "==", self.bridgeName)
+	operations = append(operations, libovsdb.Operation{
+		Op:        mutateOpr,
+		Table:     "Bridge",
+		Mutations: []interface{}{mutation},
+		Where:     []interface{}{condition},
+	})
+
+	return self.transact(operations...)
+}
+
+// setPortQoS limits the rate of the port, in bits per second, with a
+// linux-htb QoS and its queue, the way odlovs-cni does. They hold extIDs and
+// replace the QoS of the port and the QoS and queues holding extIDs; a rate
+// of 0 removes the limit.
+func (self *driver) setPortQoS(uuid string, port libovsdb.Row, maxRate int64, extIDs map[string]string) error {
+	var operations []libovsdb.Operation
+	if maxRate == 0 {
+		empty, _ := libovsdb.NewOvsSet([]libovsdb.UUID{})
+		operations = append(operations, updateOp("Port", uuid, map[string]interface{}{"qos": empty}))
+	} else {
+		qosUuidStr := "coeQos"
+		queueUuidStr := "coeQueue"
+		extIdsMap, _ := libovsdb.NewOvsMap(extIDs)
+		otherConfig, _ := libovsdb.NewOvsMap(map[string]string{"max-rate": fmt.Sprint(maxRate)})
+
+		queue := map[string]interface{}{
+			"other_config": otherConfig,
+			"external_ids": extIdsMap,
+		}
+		qos := map[string]interface{}{
+			"type":         "linux-htb",
+			"other_config": otherConfig,
+			"external_ids": extIdsMap,
+		}
+		qos["queues"], _ = libovsdb.NewOvsMap(map[int]libovsdb.UUID{0: {GoUUID: queueUuidStr}})
+		operations = append(operations,
+			libovsdb.Operation{
+				Op:       insertOpr,
+				Table:    "Queue",
+				Row:      queue,
+				UUIDName: queueUuidStr,
+			},
+			libovsdb.Operation{
+				Op:       insertOpr,
+				Table:    "QoS",
+				Row:      qos,
+				UUIDName: qosUuidStr,
+			},
+			updateOp("Port", uuid, map[string]interface{}{"qos": libovsdb.UUID{GoUUID: qosUuidStr}}))
+	}
+
+	// QoS and Queue are root tables, the replaced rows must be deleted
+	// explicitly
+	self.lock.RLock()
+	replaced := map[string]string{}
+	for _, qos := range uuids(port.Fields["qos"]) {
+		replaced[qos] = "QoS"
+	}
+	for _, table := range []string{"QoS", "Queue"} {
+		for rowUuid, row := range self.ovsdbCache[table] {
+			if hasExternalIds(row, extIDs) {
+				replaced[rowUuid] = table
+			}
+		}
+	}
+	self.lock.RUnlock()
+	for rowUuid, table := range replaced {
+		operations = append(operations, libovsdb.Operation{
+			Op:    deleteOpr,
+			Table: table,
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", libovsdb.UUID{GoUUID: rowUuid})},
+		})
+	}
+	return self.transact(operations...)
+}
+
+// qosRate returns the max-rate of the QoS of the port, "" when it has none.
+func (self *driver) qosRate(port libovsdb.Row) string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for _, qos := range uuids(port.Fields["qos"]) {
+		if row, ok := self.ovsdbCache["QoS"][qos]; ok {
+			return goMap(row.Fields["other_config"])["max-rate"]
+		}
+	}
+	return ""
+}
+
+// hasExternalIds reports whether the row holds every one of extIDs.
+func hasExternalIds(row libovsdb.Row, extIDs map[string]string) bool {
+	if len(extIDs) == 0 {
+		return false
+	}
+	ids := goMap(row.Fields["external_ids"])
+	for key, value := range extIDs {
+		if ids[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// interfaces returns the rows of the interfaces of the port, by uuid.
+func (self *driver) interfaces(port libovsdb.Row) map[string]libovsdb.Row {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	rows := make(map[string]libovsdb.Row)
+	for _, uuid := range uuids(port.Fields["interfaces"]) {
+		if row, ok := self.ovsdbCache["Interface"][uuid]; ok {
+			rows[uuid] = row
+		}
+	}
+	return rows
+}
+
+// goMap converts an OVSDB map column to a Go map.
+func goMap(column interface{}) map[string]string {
+	m := make(map[string]string)
+	if ovsMap, ok := column.(libovsdb.OvsMap); ok {
+		for key, value := range ovsMap.GoMap {
+			m[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return m
+}
+
+// uuids returns the uuids held by a column referencing rows, either a
+// single uuid or a set of them.
+func uuids(column interface{}) []string {
+	switch value := column.(type) {
+	case libovsdb.UUID:
+		return []string{value.GoUUID}
+	case libovsdb.OvsSet:
+		var ids []string
+		for _, element := range value.GoSet {
+			if uuid, ok := element.(libovsdb.UUID); ok {
+				ids = append(ids, uuid.GoUUID)
+			}
+		}
+		return ids
+	}
+	return nil
+}
+
+// ************************ Notification handler for OVS DB changes ****************
+func (self *driver) Update(context interface{}, tableUpdates libovsdb.TableUpdates) {
+	self.populateCache(tableUpdates)
+}
+
+// Disconnected exits, the watcher being restarted by its DaemonSet and
+// resynchronizing OVS once it reconnects.
+func (self *driver) Disconnected(ovsClient *libovsdb.OvsdbClient) {
+	log.Fatalln("OVSDB connection lost")
+}
+func (self *driver) Locked([]interface{}) {
+}
+func (self *driver) Stolen([]interface{}) {
+}
+func (self *driver) Echo([]interface{}) {
+}
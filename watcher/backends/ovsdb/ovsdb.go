@@ -0,0 +1,394 @@
+// Package ovsdb programs the local Open vSwitch directly through OVSDB, for
+// small or edge clusters running without an ODL instance.
+//
+// The watcher runs on every node, next to odlovs-cni. It annotates the pod
+// ports the plugin created with the pod identity and addresses, keeps one
+// tunnel port per remote node, and keeps the ingress policing and QoS of the
+// pod ports in line with the bandwidth annotations of the pods. odlovs-cni
+// sets them up when the pod is created, the watcher applies the later
+// changes of the annotations, writing the same rows the plugin does.
+package ovsdb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"reflect"
+
+	"github.com/socketplane/libovsdb"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+)
+
+// External ids set on the OVS rows.
+const (
+	// IfaceIDKey, <namespace>:<name> of the pod, and ClusterIDKey are set
+	// by odlovs-cni on the pod ports.
+	IfaceIDKey      = "iface-id"
+	ClusterIDKey    = "cluster-id"
+	PodUIDKey       = "pod-uid"
+	PodNameKey      = "pod-name"
+	PodNamespaceKey = "pod-namespace"
+	IPAddressKey    = "ip-address"
+	IPAddress6Key   = "ip-address6"
+	// ContainerIDKey and IfNameKey are set by odlovs-cni on the pod ports,
+	// and on the QoS and queues of the pods, which they identify.
+	ContainerIDKey = "container-id"
+	IfNameKey      = "ifname"
+	// NodeKey names the remote node of a tunnel port.
+	NodeKey = "k8s-node"
+)
+
+// Bandwidth annotations of the pods, as used by the CNI bandwidth plugin.
+const (
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+// Backend keeps the OVS of the local node in sync with the cluster.
+type Backend struct {
+	driver *driver
+	config Config
+}
+
+// New connects to the OVSDB of the local node.
+func New(config Config) (*Backend, error) {
+	driver, err := connect(config.Endpoint, config.Bridge)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{
+		driver: driver,
+		config: config,
+	}, nil
+}
+
+func (b *Backend) AddPod(pod *v1.Pod) error {
+	return b.syncPod(pod)
+}
+
+func (b *Backend) UpdatePod(old, new *v1.Pod) error {
+	return b.syncPod(new)
+}
+
+// DeletePod has nothing to do, odlovs-cni removing the port of the pod.
+func (b *Backend) DeletePod(pod *v1.Pod) error {
+	return nil
+}
+
+// Services and endpoints are left to kube-proxy.
+func (b *Backend) AddService(service *v1.Service) error {
+	return nil
+}
+
+func (b *Backend) UpdateService(old, new *v1.Service) error {
+	return nil
+}
+
+func (b *Backend) DeleteService(service *v1.Service) error {
+	return nil
+}
+
+func (b *Backend) AddEndpoints(endpoints *v1.Endpoints) error {
+	return nil
+}
+
+func (b *Backend) UpdateEndpoints(old, new *v1.Endpoints) error {
+	return nil
+}
+
+func (b *Backend) DeleteEndpoints(endpoints *v1.Endpoints) error {
+	return nil
+}
+
+func (b *Backend) AddNode(node *v1.Node) error {
+	return b.syncTunnel(node)
+}
+
+func (b *Backend) UpdateNode(old, new *v1.Node) error {
+	return b.syncTunnel(new)
+}
+
+func (b *Backend) DeleteNode(node *v1.Node) error {
+	uuid, port, ok := b.driver.findByName("Port", tunnelName(node.Name))
+	if !ok {
+		return nil
+	}
+	if err := b.driver.deletePort(uuid, port); err != nil {
+		log.Printf("unable to delete the tunnel to node %s: %s\n", node.Name, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Flush closes the OVSDB connection once the watcher stops.
+func (b *Backend) Flush() {
+	b.driver.disconnect()
+}
+
+// PruneTunnels deletes the tunnel ports to nodes missing from nodes, e.g.
+// removed while the watcher was not running.
+func (b *Backend) PruneTunnels(nodes []v1.Node) {
+	names := make(map[string]bool)
+	for i := range nodes {
+		names[nodes[i].Name] = true
+	}
+	for uuid, port := range b.driver.findRows("Port", NodeKey) {
+		node := goMap(port.Fields["external_ids"])[NodeKey]
+		if names[node] {
+			continue
+		}
+		log.Println("Deleting the tunnel to removed node", node)
+		if err := b.driver.deletePort(uuid, port); err != nil {
+			log.Printf("unable to delete the tunnel to node %s: %s\n", node, err.Error())
+		}
+	}
+}
+
+// syncPod sets the pod identity and addresses on the port odlovs-cni
+// created for it, and applies its bandwidth annotations.
+func (b *Backend) syncPod(pod *v1.Pod) error {
+	if pod.Spec.NodeName != b.config.NodeName || pod.Spec.HostNetwork {
+		return nil
+	}
+	// the port appears once the sandbox is set up, the pod is updated then
+	uuid, port, ok := b.podPort(pod)
+	if !ok {
+		return nil
+	}
+
+	ingress, egress, err := bandwidth(pod)
+	if err != nil {
+		log.Printf("ignoring the bandwidth of pod %s/%s: %s\n", pod.Namespace, pod.Name, err.Error())
+	}
+
+	extIDs := map[string]string{
+		PodUIDKey:       string(pod.UID),
+		PodNameKey:      pod.Name,
+		PodNamespaceKey: pod.Namespace,
+	}
+	for key, value := range podIPs(pod) {
+		extIDs[key] = value
+	}
+
+	var operations []libovsdb.Operation
+	if ids, changed := merge(port.Fields["external_ids"], extIDs); changed {
+		operations = append(operations, updateOp("Port", uuid, map[string]interface{}{"external_ids": ids}))
+	}
+	// traffic leaving the pod enters OVS through its interface, policed in
+	// kbps as odlovs-cni does
+	rate := kbps(egress)
+	burst := rate / 10
+	for intfUuid, intf := range b.driver.interfaces(port) {
+		columns := make(map[string]interface{})
+		if ids, changed := merge(intf.Fields["external_ids"], extIDs); changed {
+			columns["external_ids"] = ids
+		}
+		if !sameInt(intf.Fields["ingress_policing_rate"], rate) || !sameInt(intf.Fields["ingress_policing_burst"], burst) {
+			columns["ingress_policing_rate"] = rate
+			columns["ingress_policing_burst"] = burst
+		}
+		if len(columns) > 0 {
+			operations = append(operations, updateOp("Interface", intfUuid, columns))
+		}
+	}
+	if len(operations) > 0 {
+		if err := b.driver.transact(operations...); err != nil {
+			log.Printf("unable to update the port of pod %s/%s: %s\n", pod.Namespace, pod.Name, err.Error())
+			return err
+		}
+	}
+
+	// traffic to the pod leaves OVS through its port
+	current, desired := b.driver.qosRate(port), ""
+	if ingress != 0 {
+		desired = fmt.Sprint(ingress)
+	}
+	if current == desired {
+		return nil
+	}
+	if err := b.driver.setPortQoS(uuid, port, ingress, qosExternalIds(pod, port)); err != nil {
+		log.Printf("unable to set the QoS of pod %s/%s: %s\n", pod.Namespace, pod.Name, err.Error())
+		return err
+	}
+	return nil
+}
+
+// qosExternalIds returns the external ids identifying the QoS and queue of
+// the pod port, those odlovs-cni sets so that its DEL deletes them.
+func qosExternalIds(pod *v1.Pod, port libovsdb.Row) map[string]string {
+	ids := goMap(port.Fields["external_ids"])
+	if ids[ContainerIDKey] == "" {
+		// ports created before odlovs-cni recorded the container
+		return map[string]string{PodUIDKey: string(pod.UID)}
+	}
+	return map[string]string{
+		ContainerIDKey: ids[ContainerIDKey],
+		IfNameKey:      ids[IfNameKey],
+	}
+}
+
+// podPort returns the uuid and the row of the port odlovs-cni created for
+// the pod, skipping the ports left by former pods of the same name.
+func (b *Backend) podPort(pod *v1.Pod) (string, libovsdb.Row, bool) {
+	ifaceID := pod.Namespace + ":" + pod.Name
+	// ports created by odlovs-cni before the iface-id held the namespace
+	legacyID := b.config.ClusterID + ":" + pod.Name
+	for uuid, port := range b.driver.findRows("Port", IfaceIDKey) {
+		ids := goMap(port.Fields["external_ids"])
+		current := ids[IfaceIDKey] == ifaceID && ids[ClusterIDKey] == b.config.ClusterID
+		legacy := ids[IfaceIDKey] == legacyID && ids[ClusterIDKey] == ""
+		if !current && !legacy {
+			continue
+		}
+		if uid := ids[PodUIDKey]; uid != "" && uid != string(pod.UID) {
+			continue
+		}
+		return uuid, port, true
+	}
+	return "", libovsdb.Row{}, false
+}
+
+// podIPs returns the ip-address external ids of the pod, the first address
+// of each IP family as recorded by odlovs-cni.
+func podIPs(pod *v1.Pod) map[string]string {
+	ips := []string{pod.Status.PodIP}
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	extIDs := make(map[string]string)
+	for _, value := range ips {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			continue
+		}
+		key := IPAddressKey
+		if ip.To4() == nil {
+			key = IPAddress6Key
+		}
+		if _, ok := extIDs[key]; !ok {
+			extIDs[key] = ip.String()
+		}
+	}
+	return extIDs
+}
+
+// syncTunnel creates or updates the tunnel port to a remote node.
+func (b *Backend) syncTunnel(node *v1.Node) error {
+	if node.Name == b.config.NodeName {
+		return nil
+	}
+	remoteIP := tunnelEndpoint(node)
+	if remoteIP == "" {
+		return nil
+	}
+	options := map[string]string{
+		"remote_ip": remoteIP,
+		"key":       "flow",
+	}
+
+	name := tunnelName(node.Name)
+	_, port, ok := b.driver.findByName("Port", name)
+	if !ok {
+		if err := b.driver.createPort(name, b.config.TunnelType, options, map[string]string{NodeKey: node.Name}); err != nil {
+			log.Printf("unable to create the tunnel to node %s: %s\n", node.Name, err.Error())
+			return err
+		}
+		return nil
+	}
+
+	for intfUuid, intf := range b.driver.interfaces(port) {
+		if intf.Fields["type"] == b.config.TunnelType && reflect.DeepEqual(goMap(intf.Fields["options"]), options) {
+			continue
+		}
+		ovsOptions, _ := libovsdb.NewOvsMap(options)
+		columns := map[string]interface{}{
+			"type":    b.config.TunnelType,
+			"options": ovsOptions,
+		}
+		if err := b.driver.transact(updateOp("Interface", intfUuid, columns)); err != nil {
+			log.Printf("unable to update the tunnel to node %s: %s\n", node.Name, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// tunnelEndpoint returns the address of the node's tunnel endpoint, its
+// annotation or else its internal IP.
+func tunnelEndpoint(node *v1.Node) string {
+	if ip := node.GetAnnotations()[backends.TunnelEndpointAnnotation]; ip != "" {
+		return ip
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
+
+// tunnelName derives the port name from the node name, which may exceed the
+// 15 characters of a Linux interface name.
+func tunnelName(node string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(node))
+	return fmt.Sprintf("tun%08x", hash.Sum32())
+}
+
+// bandwidth returns the ingress and egress rates of the pod annotations, in
+// bits per second, 0 when unset.
+func bandwidth(pod *v1.Pod) (ingress, egress int64, err error) {
+	annotations := pod.GetAnnotations()
+	if ingress, err = parseRate(annotations[IngressBandwidthAnnotation]); err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", IngressBandwidthAnnotation, err)
+	}
+	if egress, err = parseRate(annotations[EgressBandwidthAnnotation]); err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", EgressBandwidthAnnotation, err)
+	}
+	return ingress, egress, nil
+}
+
+func parseRate(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() <= 0 {
+		return 0, fmt.Errorf("%s is not a positive rate", value)
+	}
+	return quantity.Value(), nil
+}
+
+// kbps converts a rate in bps to kbps, rounding up so that a limit below
+// 1 kbps does not become 0, which means no limit to OVS.
+func kbps(rate int64) int64 {
+	return (rate + 999) / 1000
+}
+
+// merge returns the OVSDB map of column with the entries of ids set, and
+// whether any of them changed.
+func merge(column interface{}, ids map[string]string) (*libovsdb.OvsMap, bool) {
+	merged := goMap(column)
+	changed := false
+	for key, value := range ids {
+		if merged[key] != value {
+			merged[key] = value
+			changed = true
+		}
+	}
+	ovsMap, _ := libovsdb.NewOvsMap(merged)
+	return ovsMap, changed
+}
+
+// sameInt compares an integer column, decoded from JSON as a float64.
+func sameInt(column interface{}, value int64) bool {
+	number, ok := column.(float64)
+	return ok && int64(number) == value
+}
@@ -0,0 +1,91 @@
+package ovsdb
+
+import (
+	"testing"
+
+	"github.com/socketplane/libovsdb"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBandwidth(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ingress     int64
+		egress      int64
+		// egress policing rate in kbps
+		rate    int64
+		invalid bool
+	}{
+		{name: "unset"},
+		{
+			name:        "both",
+			annotations: map[string]string{IngressBandwidthAnnotation: "10M", EgressBandwidthAnnotation: "1M"},
+			ingress:     10000000,
+			egress:      1000000,
+			rate:        1000,
+		},
+		{
+			name:        "below 1 kbps",
+			annotations: map[string]string{EgressBandwidthAnnotation: "500"},
+			egress:      500,
+			rate:        1,
+		},
+		{
+			name:        "invalid",
+			annotations: map[string]string{IngressBandwidthAnnotation: "fast"},
+			invalid:     true,
+		},
+		{
+			name:        "negative",
+			annotations: map[string]string{EgressBandwidthAnnotation: "-1M"},
+			invalid:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+			ingress, egress, err := bandwidth(pod)
+			if (err != nil) != test.invalid {
+				t.Fatalf("bandwidth() error = %v, expected an error: %t", err, test.invalid)
+			}
+			if ingress != test.ingress || egress != test.egress {
+				t.Errorf("bandwidth() = %d, %d, expected %d, %d", ingress, egress, test.ingress, test.egress)
+			}
+			if rate := kbps(egress); rate != test.rate {
+				t.Errorf("kbps(%d) = %d, expected %d", egress, rate, test.rate)
+			}
+		})
+	}
+}
+
+// The QoS of a pod is identified as odlovs-cni identifies it, so that its
+// DEL deletes the rows written by the watcher.
+func TestQoSExternalIds(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "pod-1"}}
+	row := func(ids map[string]string) libovsdb.Row {
+		ovsMap, _ := libovsdb.NewOvsMap(ids)
+		return libovsdb.Row{Fields: map[string]interface{}{"external_ids": *ovsMap}}
+	}
+
+	port := row(map[string]string{ContainerIDKey: "abc", IfNameKey: "eth0", IfaceIDKey: "default:web"})
+	ids := qosExternalIds(pod, port)
+	if len(ids) != 2 || ids[ContainerIDKey] != "abc" || ids[IfNameKey] != "eth0" {
+		t.Errorf("qosExternalIds() = %v, expected the container and ifname of the port", ids)
+	}
+	if !hasExternalIds(row(map[string]string{ContainerIDKey: "abc", IfNameKey: "eth0", "other": "x"}), ids) {
+		t.Error("the QoS written by odlovs-cni does not match")
+	}
+	if hasExternalIds(row(map[string]string{ContainerIDKey: "abc", IfNameKey: "eth1"}), ids) {
+		t.Error("the QoS of another interface matches")
+	}
+
+	legacy := qosExternalIds(pod, row(map[string]string{IfaceIDKey: "default:web"}))
+	if len(legacy) != 1 || legacy[PodUIDKey] != "pod-1" {
+		t.Errorf("qosExternalIds() of a legacy port = %v, expected the pod uid", legacy)
+	}
+	if hasExternalIds(row(nil), nil) {
+		t.Error("no external ids match every row")
+	}
+}
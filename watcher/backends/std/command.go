@@ -4,9 +4,9 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/journal"
 	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
 )
 
@@ -16,14 +16,17 @@ var Cmd = &cobra.Command{
 	Long:  "Watches Kubernetes and print to stdout",
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("Run STD watcher")
-		viper.ReadInConfig()
+		commands.RequireClientSet()
 
 		backend := Backend{}
 
-		backends.Watch(commands.Config.ClientSet, backend)
+		backends.Watch(commands.Config.ClientSet, journal.Use(backend))
 	},
 }
 
 func init() {
 	commands.RootCmd.AddCommand(Cmd)
+	journal.RegisterBackend("std", func() (backends.Coe, error) {
+		return Backend{}, nil
+	})
 }
@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Broker is a message bus the events are published to. Publish is called
+// concurrently by the informers.
+type Broker interface {
+	// Publish sends the payload on the topic. The key identifies the object,
+	// brokers partitioning topics should use it as the partition key.
+	Publish(topic, key string, payload []byte) error
+	// Close sends what may still be buffered and releases the connection.
+	Close() error
+}
+
+// BrokerFactory creates a broker from the stream configuration. Brokers
+// needing settings of their own read them from a section they register with
+// commands.RegisterSection.
+type BrokerFactory func(config Config) (Broker, error)
+
+var brokers = make(map[string]BrokerFactory)
+
+// RegisterBroker makes a broker available under name, for the sink key of
+// the configuration. Broker packages call it from their init function, e.g.
+// a Kafka or NATS client linked into the watcher with a blank import.
+func RegisterBroker(name string, factory BrokerFactory) {
+	if _, ok := brokers[name]; ok {
+		panic("broker registered twice: " + name)
+	}
+	brokers[name] = factory
+}
+
+// NewBroker creates the broker registered under name.
+func NewBroker(name string, config Config) (Broker, error) {
+	factory, ok := brokers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q, expected one of %s", name, strings.Join(brokerNames(), ", "))
+	}
+	return factory(config)
+}
+
+func brokerNames() []string {
+	names := make([]string, 0, len(brokers))
+	for name := range brokers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
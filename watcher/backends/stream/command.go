@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/journal"
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "stream",
+	Short: "stream watcher",
+	Long:  "Watches Kubernetes and publishes every event as a JSON envelope to a message bus",
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Println("Run stream watcher")
+		commands.RequireClientSet()
+
+		commands.BindFlags(cmd, flagKeys)
+		backend, err := newBackend()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		backends.Watch(commands.Config.ClientSet, journal.Use(backend))
+		backend.Flush()
+	},
+}
+
+// newBackend creates the backend of the stream section.
+func newBackend() (*Backend, error) {
+	var config Config
+	if err := commands.LoadSection("stream", &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	broker, err := NewBroker(config.Sink, config)
+	if err != nil {
+		return nil, err
+	}
+	return New(broker, config.Source, config.TopicPrefix), nil
+}
+
+func init() {
+	defaults := DefaultConfig()
+	Cmd.Flags().String("sink", defaults.Sink, "Broker the events are published to")
+	Cmd.Flags().String("file", defaults.File, "File the file sink appends the events to, - for stdout")
+	Cmd.Flags().String("source", defaults.Source, "Source of the events, e.g. the cluster name")
+	Cmd.Flags().String("topic-prefix", defaults.TopicPrefix, "Prefix of the topic names, followed by the kind")
+	commands.RootCmd.AddCommand(Cmd)
+	journal.RegisterBackend("stream", func() (backends.Coe, error) {
+		backend, err := newBackend()
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	})
+}
@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"fmt"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Config is the stream section of the configuration file.
+type Config struct {
+	// Sink names the registered broker the events are published to.
+	Sink string `mapstructure:"sink"`
+	// File is the path of the file sink, "-" for stdout.
+	File string `mapstructure:"file"`
+	// Source identifies the watcher, e.g. the cluster, in the envelopes.
+	Source string `mapstructure:"source"`
+	// TopicPrefix is prepended to the lower case kind to name the topics.
+	TopicPrefix string `mapstructure:"topic-prefix"`
+}
+
+// flagKeys maps the command line flags to the configuration keys they
+// override.
+var flagKeys = map[string]string{
+	"sink":         "stream.sink",
+	"file":         "stream.file",
+	"source":       "stream.source",
+	"topic-prefix": "stream.topic-prefix",
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Sink:        "file",
+		File:        "-",
+		Source:      "coe-watcher",
+		TopicPrefix: "coe.",
+	}
+}
+
+func (c *Config) Validate() error {
+	if _, ok := brokers[c.Sink]; !ok {
+		return fmt.Errorf("sink: unknown sink %q", c.Sink)
+	}
+	if c.Sink == "file" && c.File == "" {
+		return fmt.Errorf("file: required by the file sink")
+	}
+	return nil
+}
+
+func init() {
+	defaults := DefaultConfig()
+	commands.RegisterSection("stream", &defaults)
+}
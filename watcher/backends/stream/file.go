@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// fileBroker writes the payloads as newline delimited JSON, one event per
+// line, ignoring the topics. Consumers tail the file.
+type fileBroker struct {
+	lock   sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// newFileBroker appends to the file at config.File, or writes to stdout
+// when it is "-".
+func newFileBroker(config Config) (Broker, error) {
+	if config.File == "-" {
+		return &fileBroker{writer: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(config.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileBroker{writer: file, closer: file}, nil
+}
+
+func (b *fileBroker) Publish(topic, key string, payload []byte) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	line := make([]byte, 0, len(payload)+1)
+	line = append(append(line, payload...), '\n')
+	_, err := b.writer.Write(line)
+	return err
+}
+
+func (b *fileBroker) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+func init() {
+	RegisterBroker("file", newFileBroker)
+}
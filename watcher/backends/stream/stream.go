@@ -0,0 +1,161 @@
+// Package stream publishes the watched Kubernetes events to a message bus,
+// for consumers other than ODL.
+//
+// Every event is wrapped in a versioned JSON Envelope and published to the
+// topic of its kind, keyed by the object uid so that brokers partitioning by
+// key keep the events of an object in order.
+package stream
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnvelopeVersion is the version of the Envelope format. Fields may be added
+// within a version; removing or changing one requires a new version.
+const EnvelopeVersion = "coe.opendaylight.org/v1"
+
+// Event types.
+const (
+	Added    = "ADDED"
+	Modified = "MODIFIED"
+	Deleted  = "DELETED"
+)
+
+// Envelope is the message published for each event.
+type Envelope struct {
+	Version string `json:"version"`
+	// ID is unique to the event, so that consumers can drop duplicates.
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	// Kind is Pod, Service, Endpoints or Node.
+	Kind      string `json:"kind"`
+	Type      string `json:"type"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	// Object is the Kubernetes object, after the change for updates.
+	Object interface{} `json:"object"`
+	// OldObject is the object before the change, for updates only.
+	OldObject interface{} `json:"oldObject,omitempty"`
+}
+
+// Backend publishes the events to a Broker.
+type Backend struct {
+	broker      Broker
+	source      string
+	topicPrefix string
+}
+
+// New returns a backend publishing to broker, on topics named topicPrefix
+// followed by the lower case kind, with envelopes naming source.
+func New(broker Broker, source, topicPrefix string) *Backend {
+	return &Backend{
+		broker:      broker,
+		source:      source,
+		topicPrefix: topicPrefix,
+	}
+}
+
+func (b *Backend) AddPod(pod *v1.Pod) error {
+	return b.publish("Pod", Added, &pod.ObjectMeta, pod, nil)
+}
+
+func (b *Backend) UpdatePod(old, new *v1.Pod) error {
+	return b.publish("Pod", Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeletePod(pod *v1.Pod) error {
+	return b.publish("Pod", Deleted, &pod.ObjectMeta, pod, nil)
+}
+
+func (b *Backend) AddService(service *v1.Service) error {
+	return b.publish("Service", Added, &service.ObjectMeta, service, nil)
+}
+
+func (b *Backend) UpdateService(old, new *v1.Service) error {
+	return b.publish("Service", Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteService(service *v1.Service) error {
+	return b.publish("Service", Deleted, &service.ObjectMeta, service, nil)
+}
+
+func (b *Backend) AddEndpoints(endpoints *v1.Endpoints) error {
+	return b.publish("Endpoints", Added, &endpoints.ObjectMeta, endpoints, nil)
+}
+
+func (b *Backend) UpdateEndpoints(old, new *v1.Endpoints) error {
+	return b.publish("Endpoints", Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteEndpoints(endpoints *v1.Endpoints) error {
+	return b.publish("Endpoints", Deleted, &endpoints.ObjectMeta, endpoints, nil)
+}
+
+func (b *Backend) AddNode(node *v1.Node) error {
+	return b.publish("Node", Added, &node.ObjectMeta, node, nil)
+}
+
+func (b *Backend) UpdateNode(old, new *v1.Node) error {
+	return b.publish("Node", Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteNode(node *v1.Node) error {
+	return b.publish("Node", Deleted, &node.ObjectMeta, node, nil)
+}
+
+// Flush closes the broker once the watcher stops, sending what it buffered.
+func (b *Backend) Flush() {
+	if err := b.broker.Close(); err != nil {
+		log.Println("unable to close the broker:", err)
+	}
+}
+
+func (b *Backend) publish(kind, eventType string, meta *metav1.ObjectMeta, object, oldObject interface{}) error {
+	envelope := NewEnvelope(b.source, kind, eventType, meta, object, oldObject)
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	if err := b.broker.Publish(b.topicPrefix+strings.ToLower(kind), envelope.UID, payload); err != nil {
+		log.Printf("unable to publish %s %s %s/%s: %s\n", eventType, kind, meta.Namespace, meta.Name, err.Error())
+		return err
+	}
+	return nil
+}
+
+// NewEnvelope wraps an event, oldObject being nil unless the event is an
+// update.
+func NewEnvelope(source, kind, eventType string, meta *metav1.ObjectMeta, object, oldObject interface{}) Envelope {
+	envelope := Envelope{
+		Version:   EnvelopeVersion,
+		ID:        newID(),
+		Time:      time.Now().UTC(),
+		Source:    source,
+		Kind:      kind,
+		Type:      eventType,
+		Namespace: meta.Namespace,
+		Name:      meta.Name,
+		UID:       string(meta.UID),
+		Object:    object,
+	}
+	if oldObject != nil {
+		envelope.OldObject = oldObject
+	}
+	return envelope
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
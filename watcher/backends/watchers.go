@@ -2,12 +2,14 @@ package backends
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"sync"
 	"time"
 
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -166,3 +168,44 @@ func watchNodes(informer informers.SharedInformerFactory, wg *sync.WaitGroup, ba
 	nodeInformer.Informer().Run(shutdown)
 	wg.Done()
 }
+
+// Resync lists every object from Kubernetes and adds it again to the backend,
+// e.g. after the backend lost track of some events.
+func Resync(clientSet kubernetes.Interface, backend Coe) {
+	log.Println("Resyncing all objects")
+	nodes, err := clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		log.Println("unable to list nodes:", err)
+	} else {
+		for i := range nodes.Items {
+			backend.AddNode(&nodes.Items[i])
+		}
+	}
+
+	pods, err := clientSet.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		log.Println("unable to list pods:", err)
+	} else {
+		for i := range pods.Items {
+			backend.AddPod(&pods.Items[i])
+		}
+	}
+
+	services, err := clientSet.CoreV1().Services(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		log.Println("unable to list services:", err)
+	} else {
+		for i := range services.Items {
+			backend.AddService(&services.Items[i])
+		}
+	}
+
+	endpoints, err := clientSet.CoreV1().Endpoints(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		log.Println("unable to list endpoints:", err)
+	} else {
+		for i := range endpoints.Items {
+			backend.AddEndpoints(&endpoints.Items[i])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends"
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/journal"
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "webhook watcher",
+	Long:  "Watches Kubernetes and posts every event to HTTP endpoints",
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Println("Run webhook watcher")
+		commands.RequireClientSet()
+
+		commands.BindFlags(cmd, flagKeys)
+		backend, err := newBackend()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		backends.Watch(commands.Config.ClientSet, journal.Use(backend))
+		backend.Flush()
+	},
+}
+
+// newBackend creates the backend of the webhook section.
+func newBackend() (*Backend, error) {
+	var config Config
+	if err := commands.LoadSection("webhook", &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	if !config.hasTarget() {
+		return nil, fmt.Errorf("no webhook URL configured, set webhook.url or webhook.urls")
+	}
+	if config.HMACSecretFile != "" {
+		secret, err := ioutil.ReadFile(config.HMACSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the HMAC secret: %v", err)
+		}
+		config.HMACSecret = strings.TrimSpace(string(secret))
+	}
+	return New(config)
+}
+
+func init() {
+	defaults := DefaultConfig()
+	Cmd.Flags().String("url", defaults.URL, "URL receiving the events of every kind without a URL of its own")
+	Cmd.Flags().Duration("batch-window", defaults.Batch.Window, "Buffer events for this long and post them as a JSON array (0 disables batching)")
+	commands.RootCmd.AddCommand(Cmd)
+	journal.RegisterBackend("webhook", func() (backends.Coe, error) {
+		backend, err := newBackend()
+		if err != nil {
+			return nil, err
+		}
+		return backend, nil
+	})
+}
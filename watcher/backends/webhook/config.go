@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+
+	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
+)
+
+// Config is the webhook section of the configuration file.
+type Config struct {
+	// URL receives the events of the kinds without a URL of their own.
+	URL  string     `mapstructure:"url"`
+	URLs KindConfig `mapstructure:"urls"`
+	// Template renders the body of an event, with the stream.Envelope of
+	// the event as data. The envelope itself is sent when it is empty.
+	Template  string     `mapstructure:"template"`
+	Templates KindConfig `mapstructure:"templates"`
+	// Source identifies the watcher in the envelopes.
+	Source string `mapstructure:"source"`
+	// Headers are added to every request.
+	Headers map[string]string `mapstructure:"headers"`
+	// HMACSecret signs the bodies, see SignatureHeader. It is only read
+	// from the configuration file or the environment, or from
+	// HMACSecretFile, e.g. where a Kubernetes Secret is mounted, so that
+	// it never shows on the command line.
+	HMACSecret     string        `mapstructure:"hmac-secret" secret:"true"`
+	HMACSecretFile string        `mapstructure:"hmac-secret-file"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+
+	Retry RetryConfig `mapstructure:"retry"`
+	Batch BatchConfig `mapstructure:"batch"`
+}
+
+// KindConfig holds a setting per kind of object, overriding the common one
+// when set.
+type KindConfig struct {
+	Pod       string `mapstructure:"pod"`
+	Service   string `mapstructure:"service"`
+	Endpoints string `mapstructure:"endpoints"`
+	Node      string `mapstructure:"node"`
+}
+
+// RetryConfig configures the retries of failed deliveries.
+type RetryConfig struct {
+	// Attempts is the number of deliveries tried, the first included.
+	Attempts int `mapstructure:"attempts"`
+	// Backoff is the delay before the first retry, doubled at each retry.
+	Backoff time.Duration `mapstructure:"backoff"`
+}
+
+// BatchConfig configures the batching of events: when Window is not zero,
+// events are buffered for up to Window and sent as a JSON array of at most
+// Size bodies.
+type BatchConfig struct {
+	Window time.Duration `mapstructure:"window"`
+	Size   int           `mapstructure:"size"`
+}
+
+// flagKeys maps the command line flags to the configuration keys they
+// override.
+var flagKeys = map[string]string{
+	"url":          "webhook.url",
+	"batch-window": "webhook.batch.window",
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Source:  "coe-watcher",
+		Timeout: 10 * time.Second,
+		Retry: RetryConfig{
+			Attempts: 3,
+			Backoff:  time.Second,
+		},
+		Batch: BatchConfig{
+			Size: 100,
+		},
+	}
+}
+
+// get returns the setting of the kind, or the common one.
+func (k KindConfig) get(kind, common string) string {
+	var value string
+	switch kind {
+	case "Pod":
+		value = k.Pod
+	case "Service":
+		value = k.Service
+	case "Endpoints":
+		value = k.Endpoints
+	case "Node":
+		value = k.Node
+	}
+	if value == "" {
+		return common
+	}
+	return value
+}
+
+// hasTarget tells whether the events of at least one kind are posted.
+func (c *Config) hasTarget() bool {
+	for _, kind := range kinds {
+		if c.URLs.get(kind, c.URL) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) Validate() error {
+	for _, kind := range kinds {
+		target := c.URLs.get(kind, c.URL)
+		if target == "" {
+			continue
+		}
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url: %q is not an http or https url", target)
+		}
+		if text := c.Templates.get(kind, c.Template); text != "" {
+			if _, err := template.New(kind).Funcs(funcs).Parse(text); err != nil {
+				return fmt.Errorf("template: %v", err)
+			}
+		}
+	}
+	if c.HMACSecret != "" && c.HMACSecretFile != "" {
+		return fmt.Errorf("hmac-secret and hmac-secret-file are mutually exclusive")
+	}
+	if c.Retry.Attempts < 1 {
+		return fmt.Errorf("retry.attempts: must be at least 1")
+	}
+	if c.Retry.Backoff < 0 {
+		return fmt.Errorf("retry.backoff: must not be negative")
+	}
+	if c.Batch.Window < 0 {
+		return fmt.Errorf("batch.window: must not be negative")
+	}
+	if c.Batch.Size < 1 {
+		return fmt.Errorf("batch.size: must be positive")
+	}
+	return nil
+}
+
+func init() {
+	defaults := DefaultConfig()
+	commands.RegisterSection("webhook", &defaults)
+}
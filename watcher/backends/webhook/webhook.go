@@ -0,0 +1,246 @@
+// Package webhook posts the watched Kubernetes events to HTTP endpoints, so
+// that other controllers or inventories can follow the cluster without
+// linking against the watcher.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"git.opendaylight.org/gerrit/p/coe.git/watcher/backends/stream"
+)
+
+// SignatureHeader carries "sha256=" followed by the hex encoded HMAC-SHA256
+// of the body, keyed with the configured secret.
+const SignatureHeader = "X-Coe-Signature"
+
+var kinds = []string{"Pod", "Service", "Endpoints", "Node"}
+
+// funcs are available to the templates: json renders a value as JSON.
+var funcs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		js, err := json.Marshal(value)
+		return string(js), err
+	},
+}
+
+// Backend posts every event to the URL of its kind.
+type Backend struct {
+	client    *http.Client
+	config    Config
+	templates map[string]*template.Template
+
+	lock    sync.Mutex
+	pending map[string][]json.RawMessage
+	timer   *time.Timer
+}
+
+// New returns a backend posting the events as configured.
+func New(config Config) (*Backend, error) {
+	b := &Backend{
+		client:    &http.Client{Timeout: config.Timeout},
+		config:    config,
+		templates: make(map[string]*template.Template),
+		pending:   make(map[string][]json.RawMessage),
+	}
+	for _, kind := range kinds {
+		text := config.Templates.get(kind, config.Template)
+		if text == "" {
+			continue
+		}
+		t, err := template.New(kind).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		b.templates[kind] = t
+	}
+	return b, nil
+}
+
+func (b *Backend) AddPod(pod *v1.Pod) error {
+	return b.post("Pod", stream.Added, &pod.ObjectMeta, pod, nil)
+}
+
+func (b *Backend) UpdatePod(old, new *v1.Pod) error {
+	return b.post("Pod", stream.Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeletePod(pod *v1.Pod) error {
+	return b.post("Pod", stream.Deleted, &pod.ObjectMeta, pod, nil)
+}
+
+func (b *Backend) AddService(service *v1.Service) error {
+	return b.post("Service", stream.Added, &service.ObjectMeta, service, nil)
+}
+
+func (b *Backend) UpdateService(old, new *v1.Service) error {
+	return b.post("Service", stream.Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteService(service *v1.Service) error {
+	return b.post("Service", stream.Deleted, &service.ObjectMeta, service, nil)
+}
+
+func (b *Backend) AddEndpoints(endpoints *v1.Endpoints) error {
+	return b.post("Endpoints", stream.Added, &endpoints.ObjectMeta, endpoints, nil)
+}
+
+func (b *Backend) UpdateEndpoints(old, new *v1.Endpoints) error {
+	return b.post("Endpoints", stream.Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteEndpoints(endpoints *v1.Endpoints) error {
+	return b.post("Endpoints", stream.Deleted, &endpoints.ObjectMeta, endpoints, nil)
+}
+
+func (b *Backend) AddNode(node *v1.Node) error {
+	return b.post("Node", stream.Added, &node.ObjectMeta, node, nil)
+}
+
+func (b *Backend) UpdateNode(old, new *v1.Node) error {
+	return b.post("Node", stream.Modified, &new.ObjectMeta, new, old)
+}
+
+func (b *Backend) DeleteNode(node *v1.Node) error {
+	return b.post("Node", stream.Deleted, &node.ObjectMeta, node, nil)
+}
+
+// Flush sends the batched events.
+func (b *Backend) Flush() {
+	b.lock.Lock()
+	pending := b.pending
+	b.pending = make(map[string][]json.RawMessage)
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.lock.Unlock()
+
+	for target, bodies := range pending {
+		for len(bodies) > 0 {
+			n := len(bodies)
+			if n > b.config.Batch.Size {
+				n = b.config.Batch.Size
+			}
+			js, err := json.Marshal(bodies[:n])
+			if err == nil {
+				err = b.send(target, js)
+			}
+			if err != nil {
+				log.Printf("unable to post %d events to %s: %s\n", n, target, err.Error())
+			}
+			bodies = bodies[n:]
+		}
+	}
+}
+
+// post renders the event and sends it, or buffers it in batch mode.
+func (b *Backend) post(kind, eventType string, meta *metav1.ObjectMeta, object, oldObject interface{}) error {
+	target := b.config.URLs.get(kind, b.config.URL)
+	if target == "" {
+		return nil
+	}
+
+	body, err := b.render(kind, stream.NewEnvelope(b.config.Source, kind, eventType, meta, object, oldObject))
+	if err != nil {
+		log.Printf("unable to render %s %s %s/%s: %s\n", eventType, kind, meta.Namespace, meta.Name, err.Error())
+		return err
+	}
+
+	if b.config.Batch.Window > 0 {
+		b.lock.Lock()
+		b.pending[target] = append(b.pending[target], body)
+		full := len(b.pending[target]) >= b.config.Batch.Size
+		if b.timer == nil && !full {
+			b.timer = time.AfterFunc(b.config.Batch.Window, b.Flush)
+		}
+		b.lock.Unlock()
+		if full {
+			b.Flush()
+		}
+		return nil
+	}
+
+	if err := b.send(target, body); err != nil {
+		log.Printf("unable to post %s %s %s/%s to %s: %s\n", eventType, kind, meta.Namespace, meta.Name, target, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (b *Backend) render(kind string, envelope stream.Envelope) (json.RawMessage, error) {
+	t, ok := b.templates[kind]
+	if !ok {
+		return json.Marshal(envelope)
+	}
+	var body bytes.Buffer
+	if err := t.Execute(&body, envelope); err != nil {
+		return nil, err
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("the %s template did not render valid JSON", kind)
+	}
+	return body.Bytes(), nil
+}
+
+// send posts the body, retrying transport errors, server errors and 429.
+func (b *Backend) send(target string, body []byte) error {
+	backoff := b.config.Retry.Backoff
+	var err error
+	for attempt := 1; attempt <= b.config.Retry.Attempts; attempt++ {
+		var retry bool
+		retry, err = b.sendOnce(target, body)
+		if err == nil || !retry {
+			return err
+		}
+		if attempt < b.config.Retry.Attempts {
+			log.Printf("posting to %s failed, retrying in %s: %s\n", target, backoff, err.Error())
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+func (b *Backend) sendOnce(target string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range b.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if b.config.HMACSecret != "" {
+		mac := hmac.New(sha256.New, []byte(b.config.HMACSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry = res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("HTTP server responded with %s", res.Status)
+}
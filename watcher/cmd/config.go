@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPrefix prefixes the environment variables overriding configuration
+// keys: odl.batch.window is read from COE_ODL_BATCH_WINDOW.
+const EnvPrefix = "COE"
+
+// Section is a typed part of the configuration file, e.g. everything under
+// the odl key. Its fields are mapped to the keys with mapstructure tags, and
+// fields tagged `secret:"true"` are masked when the configuration is printed.
+type Section interface {
+	// Validate checks the values once they are loaded.
+	Validate() error
+}
+
+var (
+	sections     = make(map[string]Section)
+	sectionNames []string
+
+	reloadLock      sync.Mutex
+	reloadListeners []func()
+)
+
+// KubeSection is the kube section of the configuration.
+type KubeSection struct {
+	// Config is the kubeconfig file; the in-cluster configuration is used
+	// when it is empty.
+	Config string `mapstructure:"config"`
+}
+
+func (s *KubeSection) Validate() error {
+	if s.Config == "" {
+		return nil
+	}
+	if _, err := os.Stat(expandHome(s.Config)); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	return nil
+}
+
+// RegisterSection declares the section stored under key name, defaults
+// being a pointer to a struct holding the default values. It has to be
+// called from init functions, before the configuration is read.
+func RegisterSection(name string, defaults Section) {
+	if _, ok := sections[name]; ok {
+		panic("configuration section registered twice: " + name)
+	}
+	sections[name] = defaults
+	sectionNames = append(sectionNames, name)
+	sort.Strings(sectionNames)
+	for key, value := range flatten(name, defaults) {
+		viper.SetDefault(key, value)
+	}
+}
+
+// LoadSection fills section, a pointer to the type registered under name,
+// from the configuration file, the environment and the bound flags, then
+// validates it.
+func LoadSection(name string, section Section) error {
+	settings, _ := viper.AllSettings()[name].(map[string]interface{})
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           section,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(settings); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if err := section.Validate(); err != nil {
+		return fmt.Errorf("%s.%v", name, err)
+	}
+	return nil
+}
+
+// BindFlags binds the flags of cmd to configuration keys, flag name to key,
+// so that a flag given on the command line wins over the file and the
+// environment. Flags missing from cmd are ignored.
+func BindFlags(cmd *cobra.Command, keys map[string]string) {
+	for flag, key := range keys {
+		if f := cmd.Flags().Lookup(flag); f != nil {
+			viper.BindPFlag(key, f)
+		}
+	}
+}
+
+// OnReload registers a function called after the configuration file changed
+// and was read again. The sections have to be loaded again by the function.
+func OnReload(listener func()) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadListeners = append(reloadListeners, listener)
+}
+
+// watchConfig reloads the configuration file whenever it changes, e.g. when
+// the ConfigMap it is mounted from is updated.
+func watchConfig() {
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		log.Println("Configuration file changed:", event.Name)
+		if err := validateConfig(); err != nil {
+			log.Println("Ignoring the invalid configuration:", err)
+			return
+		}
+		reloadLock.Lock()
+		listeners := append([]func(){}, reloadListeners...)
+		reloadLock.Unlock()
+		for _, listener := range listeners {
+			listener()
+		}
+	})
+	viper.WatchConfig()
+}
+
+// validateConfig loads every section and checks that the configuration file
+// holds no unknown key.
+func validateConfig() error {
+	var problems []string
+	for _, name := range sectionNames {
+		section := newSection(name)
+		if err := LoadSection(name, section); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, name := range sectionNames {
+		for key := range flatten(name, sections[name]) {
+			known[key] = true
+		}
+	}
+	for _, key := range viper.AllKeys() {
+		if !known[key] && !known[parentKey(key)] {
+			problems = append(problems, key+": unknown key")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// parentKey returns the key holding key, so that the entries of map fields
+// are accepted.
+func parentKey(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// newSection returns a pointer to a new zero value of the registered type.
+func newSection(name string) Section {
+	return reflect.New(reflect.TypeOf(sections[name]).Elem()).Interface().(Section)
+}
+
+// flatten lists the leaf keys of a section with their values.
+func flatten(prefix string, section interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	flattenValue(prefix, reflect.Indirect(reflect.ValueOf(section)), values, false)
+	return values
+}
+
+// flattenMasked lists the leaf keys of a section with printable values:
+// durations as strings and secrets masked.
+func flattenMasked(prefix string, section interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	flattenValue(prefix, reflect.Indirect(reflect.ValueOf(section)), values, true)
+	return values
+}
+
+func flattenValue(prefix string, value reflect.Value, values map[string]interface{}, mask bool) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "." + name
+		fieldValue := value.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			flattenValue(key, fieldValue, values, mask)
+		case mask && field.Tag.Get("secret") == "true":
+			if fieldValue.String() != "" {
+				values[key] = "********"
+			} else {
+				values[key] = ""
+			}
+		case mask && field.Type == reflect.TypeOf(time.Duration(0)):
+			values[key] = time.Duration(fieldValue.Int()).String()
+		default:
+			values[key] = fieldValue.Interface()
+		}
+	}
+}
+
+// nest turns flat dotted keys back into nested maps, for printing.
+func nest(values map[string]interface{}) map[string]interface{} {
+	nested := make(map[string]interface{})
+	for key, value := range values {
+		path := strings.Split(key, ".")
+		current := nested
+		for _, part := range path[:len(path)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[part] = next
+			}
+			current = next
+		}
+		current[path[len(path)-1]] = value
+	}
+	return nested
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the watcher configuration",
+	Long: `The configuration is read from the file given by --config, or coe.yaml in
+the current directory, $HOME or /etc/coe. Every key can be overridden by an
+environment variable named after it, e.g. ` + EnvPrefix + `_ODL_BATCH_WINDOW for
+odl.batch.window, and by the matching command line flag.`,
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration, secrets masked",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		values := make(map[string]interface{})
+		for _, name := range sectionNames {
+			section := newSection(name)
+			if err := LoadSection(name, section); err != nil {
+				log.Println(err)
+			}
+			for key, value := range flattenMasked(name, section) {
+				values[key] = value
+			}
+		}
+		out, err := yaml.Marshal(nest(values))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		os.Stdout.Write(out)
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration, exiting with status 1 when it is invalid",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateConfig(); err != nil {
+			fmt.Println("Invalid configuration:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration is valid")
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+	configCmd.AddCommand(configValidateCmd)
+	RootCmd.AddCommand(configCmd)
+}
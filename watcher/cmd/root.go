@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -61,19 +62,15 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	// Here you will define your flags and configuration settings.
-	// Cobra supports Persistent Flags, which, if defined here,
-	// will be global for your application.
+	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is coe.yaml in the current directory, $HOME or /etc/coe)")
+	RootCmd.PersistentFlags().String("kubeconfig", "", "kubeconfig file (default is the in-cluster configuration)")
+	viper.BindPFlag("kube.config", RootCmd.PersistentFlags().Lookup("kubeconfig"))
 
-	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/coe.yaml)")
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	RegisterSection("kube", &KubeSection{})
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	fmt.Println("cfgFile: " + cfgFile)
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -81,37 +78,45 @@ func initConfig() {
 		viper.AddConfigPath(".")
 		viper.AddConfigPath("$HOME")    // adding home directory as first search path
 		viper.AddConfigPath("/etc/coe") // adding /etc/coe directory as search path
-		viper.AutomaticEnv()            // read in environment variables that match
 	}
+	// read in environment variables that match, e.g. COE_ODL_HOST for odl.host
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
 
-	// If a config file is found, read it in.
+	// If a config file is found, read it in and follow its changes.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		log.Println("Using config file:", viper.ConfigFileUsed())
+		watchConfig()
+	} else if cfgFile != "" {
+		log.Fatalln(err)
 	}
 
-	var err error
-	kubeConfigFile := viper.GetString("kube.config")
-	if kubeConfigFile == "" {
-		kubeConfigFile, err = RootCmd.Flags().GetString("kubeconfig")
-		if err != nil {
-			kubeConfigFile = ""
-		}
+	var kube KubeSection
+	if err := LoadSection("kube", &kube); err != nil {
+		log.Println("Invalid Kubernetes configuration:", err)
+		return
 	}
-
-	kubeConfigFile, err = homedir.Expand(kubeConfigFile)
+	kubeConfigFile := expandHome(kube.Config)
 	log.Println("Kubeconfig: ", kubeConfigFile)
-	if err != nil {
-		log.Fatalln(err)
-	}
+	OnReload(func() {
+		var reloaded KubeSection
+		if LoadSection("kube", &reloaded) == nil && reloaded != kube {
+			log.Println("kube.config changed, it takes effect after a restart")
+		}
+	})
 
 	var config *rest.Config
+	var err error
 	if kubeConfigFile == "" {
 		config, err = rest.InClusterConfig()
 	} else {
 		config, err = clientcmd.BuildConfigFromFlags("", kubeConfigFile)
-		if err != nil {
-			panic(err.Error())
-		}
+	}
+	if err != nil {
+		// commands only talking to ODL do not need Kubernetes
+		log.Println("No Kubernetes configuration:", err)
+		return
 	}
 
 	clientSet, err := kubernetes.NewForConfig(config)
@@ -123,6 +128,22 @@ func initConfig() {
 
 }
 
+// RequireClientSet exits when no Kubernetes client could be configured.
+func RequireClientSet() {
+	if Config.ClientSet == nil {
+		log.Fatalln("no Kubernetes configuration, set kube.config or --kubeconfig, or run inside a cluster")
+	}
+}
+
+// expandHome expands a leading ~ in path.
+func expandHome(path string) string {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return path
+	}
+	return expanded
+}
+
 type CoeState struct {
 	ClientSet kubernetes.Clientset
 	Backend   backends.Coe
@@ -2,7 +2,10 @@ package main
 
 import (
 	_ "git.opendaylight.org/gerrit/p/coe.git/watcher/backends/odl"
+	_ "git.opendaylight.org/gerrit/p/coe.git/watcher/backends/ovsdb"
 	_ "git.opendaylight.org/gerrit/p/coe.git/watcher/backends/std"
+	_ "git.opendaylight.org/gerrit/p/coe.git/watcher/backends/stream"
+	_ "git.opendaylight.org/gerrit/p/coe.git/watcher/backends/webhook"
 
 	commands "git.opendaylight.org/gerrit/p/coe.git/watcher/cmd"
 )